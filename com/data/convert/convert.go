@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"github.com/sail-services/sail-go/com/data/number"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -19,14 +20,35 @@ type (
 	argInt []int
 )
 
+var (
+	ErrRSAPubKey = errors.New("convert: invalid rsa public key")
+)
+
 func TimeToTimestamp(t time.Time) int64 {
 	return t.UnixNano() / int64(time.Millisecond)
 }
 
+// 无效时返回 nil
 func RSAPubKeySToRSAPubKey(str string) *rsa.PublicKey {
+	key, _ := RSAPubKeySParse(str)
+	return key
+}
+
+// 解析 PEM 格式的 PKIX RSA 公钥
+func RSAPubKeySParse(str string) (*rsa.PublicKey, error) {
 	block, _ := pem.Decode([]byte(str))
-	key, _ := x509.ParsePKIXPublicKey(block.Bytes)
-	return key.(*rsa.PublicKey)
+	if block == nil {
+		return nil, ErrRSAPubKey
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrRSAPubKey
+	}
+	return pub, nil
 }
 
 func RSAPrivKeySToRSAPrivKey(str string) *rsa.PrivateKey {
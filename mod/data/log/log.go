@@ -14,6 +14,7 @@ import (
 type (
 	Log struct {
 		prefix            string
+		parent            *Log
 		mutex             *sync.Mutex
 		level             log_level
 		data_type         data_type
@@ -108,6 +109,29 @@ func LevelGetByS(str string) log_level {
 // Log
 // ======================
 
+// 创建带前缀的子日志, 与原日志共享输出
+func (log *Log) PrefixNew(prefix string) *Log {
+	return &Log{
+		prefix:    log.prefix + prefix,
+		parent:    log.rootGet(),
+		level:     log.level,
+		data_type: log.data_type,
+		formatter: log.formatter,
+		mutex:     log.mutex,
+	}
+}
+
+func (log *Log) PrefixGet() string {
+	return log.prefix
+}
+
+func (log *Log) rootGet() *Log {
+	if log.parent != nil {
+		return log.parent
+	}
+	return log
+}
+
 func (log *Log) LevelSet(level log_level) {
 	log.level = level
 }
@@ -117,6 +141,7 @@ func (log *Log) LevelGet() log_level {
 }
 
 func (log *Log) Close() {
+	log = log.rootGet()
 	log.mutex.Lock()
 	if log.write_closer != nil {
 		log.write_closer.Close()
@@ -128,6 +153,7 @@ func (log *Log) Close() {
 }
 
 func (log *Log) Writer(str string) {
+	log = log.rootGet()
 	if log.writer != nil {
 		log.writer.Write([]byte(str))
 	}
@@ -137,20 +163,29 @@ func (log *Log) Writer(str string) {
 }
 
 func (log *Log) WriterGet() *io.Writer {
-	return &log.writer
+	return &log.rootGet().writer
+}
+
+// 不加前缀与格式直接写入, 如 JSON 行等已格式化的内容
+func (log *Log) Write(p []byte) (int, error) {
+	log = log.rootGet()
+	log.mutex.Lock()
+	err := log.fileCheck()
+	log.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	log.Writer(string(p))
+	return len(p), nil
 }
 
 func (log *Log) Format(t time.Time, level log_level, message string) string {
+	message = log.prefix + message
+	log = log.rootGet()
 	log.mutex.Lock()
-	if len(log.file_path) != 0 && log.file_name != time.Now().Format("02PM") {
-		fs.PathNew(log.file_path+"/"+time.Now().Format("200601"), 0750)
-		log.file_name = time.Now().Format("02pm")
-		file, err := os.OpenFile(fmt.Sprintf("%s/%s/%s.log", log.file_path, time.Now().Format("200601"), log.file_name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
-		if err != nil {
-			return ""
-		}
-		log.file_writer = file
-		log.file_write_closer = file
+	if log.fileCheck() != nil {
+		log.mutex.Unlock()
+		return ""
 	}
 	var msg string
 	if log.formatter != nil {
@@ -160,16 +195,35 @@ func (log *Log) Format(t time.Time, level log_level, message string) string {
 	return msg
 }
 
+// 按小时切换日志文件, 调用时需持有锁
+func (log *Log) fileCheck() error {
+	if len(log.file_path) == 0 || log.file_name == time.Now().Format("02pm") {
+		return nil
+	}
+	fs.PathNew(log.file_path+"/"+time.Now().Format("200601"), 0750)
+	log.file_name = time.Now().Format("02pm")
+	file, err := os.OpenFile(fmt.Sprintf("%s/%s/%s.log", log.file_path, time.Now().Format("200601"), log.file_name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	if log.file_write_closer != nil {
+		log.file_write_closer.Close()
+	}
+	log.file_writer = file
+	log.file_write_closer = file
+	return nil
+}
+
 func (log *Log) Print(v ...interface{}) {
-	log.Writer(fmt.Sprint(v...))
+	log.Writer(log.prefix + fmt.Sprint(v...))
 }
 
 func (log *Log) Println(v ...interface{}) {
-	log.Writer(fmt.Sprintln(v...))
+	log.Writer(log.prefix + fmt.Sprintln(v...))
 }
 
 func (log *Log) Printf(format string, v ...interface{}) {
-	log.Writer(fmt.Sprintf(format, v...))
+	log.Writer(log.prefix + fmt.Sprintf(format, v...))
 }
 
 func (log *Log) log(level log_level, v ...interface{}) {
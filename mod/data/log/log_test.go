@@ -1,6 +1,7 @@
 package log_test
 
 import (
+	"bytes"
 	"os"
 	"github.com/sail-services/sail-go/mod/data/log"
 	"testing"
@@ -19,3 +20,22 @@ func Test_File(t *testing.T) {
 	logger.Warnln("warning message")
 	logger.Errorln("error message")
 }
+
+func Test_Prefix(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := log.New(buf, log.LEVEL_INFO, log.DATA_BASIC)
+	sub := logger.PrefixNew("<id> ")
+	sub.Errorln("error message")
+	if buf.String() != "[ERRO] <id> error message\n" {
+		t.Errorf("prefix not written, got %q", buf.String())
+	}
+}
+
+func Test_Write(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := log.New(buf, log.LEVEL_INFO, log.DATA_BASIC)
+	logger.PrefixNew("<id> ").Write([]byte("{\"a\":1}\n"))
+	if buf.String() != "{\"a\":1}\n" {
+		t.Errorf("raw write changed, got %q", buf.String())
+	}
+}
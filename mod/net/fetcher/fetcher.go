@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
@@ -29,6 +30,17 @@ type Fetcher struct {
 	Cache     map[string]cache_response `json:"-"`
 }
 
+type request_id_key struct{}
+
+const (
+	_HEADER_REQUEST_ID = "X-Request-ID"
+)
+
+// 返回带有请求 ID 的 Context, 用此 Context 发出的请求带上 X-Request-ID
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, request_id_key{}, id)
+}
+
 func New(host string) (f *Fetcher) {
 	f = fetchNew(nil)
 	f.Host = host
@@ -150,8 +162,12 @@ func (f *Fetcher) GetBody(path string) (body []byte, err error) {
 }
 
 func (f *Fetcher) Get(path string) (resp *http.Response, err error) {
+	return f.GetContext(context.Background(), path)
+}
+
+func (f *Fetcher) GetContext(ctx context.Context, path string) (resp *http.Response, err error) {
 	path = f.makeUrl(path)
-	req, err := http.NewRequest("GET", path, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
 	if err != nil {
 		return
 	}
@@ -188,8 +204,12 @@ func (f *Fetcher) GetWithNoCache(path string) (resp *http.Response, err error) {
 }
 
 func (f *Fetcher) Post(path, contentType string, content io.Reader) (resp *http.Response, err error) {
+	return f.PostContext(context.Background(), path, contentType, content)
+}
+
+func (f *Fetcher) PostContext(ctx context.Context, path, contentType string, content io.Reader) (resp *http.Response, err error) {
 	path = f.makeUrl(path)
-	req, err := http.NewRequest("POST", path, content)
+	req, err := http.NewRequestWithContext(ctx, "POST", path, content)
 	if err != nil {
 		return
 	}
@@ -360,6 +380,9 @@ func (f *Fetcher) makeOtherHeader(req *http.Request) (err error) {
 	req.Header.Set("Origin", origin)
 	req.Header.Set("User-Agent", user_agent)
 	//	req.Header.Set("X-Requested-With", x_request_with)
+	if id, _ := req.Context().Value(request_id_key{}).(string); id != "" {
+		req.Header.Set(_HEADER_REQUEST_ID, id)
+	}
 	for key, val := range f.Header.Custom {
 		req.Header.Set(key, val)
 	}
@@ -1,8 +1,11 @@
 package service
 
 import (
-	"github.com/sail-services/sail-go/mod/data/log"
+	"context"
 	"math"
+	"net/http"
+
+	"github.com/sail-services/sail-go/mod/data/log"
 )
 
 type (
@@ -19,30 +22,82 @@ type (
 		}
 		data         map[string]interface{}
 		handlers     []Handler
-		handlerIndex int8
+		handlerIndex int
+		err          error
 		resp         response
 	}
 	contextOpts struct {
-		Stop bool
-		Log  bool
+		Stop bool // 停止执行后续处理, 与 Abort 相同 [false]
+		Log  bool // 记录访问日志 [true]
 	}
 )
 
+const (
+	_ABORT_INDEX = math.MaxInt32
+)
+
 // Context
 func (con *Context) Next() {
 	con.handlerIndex++
-	s := int8(len(con.handlers))
-	for ; con.handlerIndex < s; con.handlerIndex++ {
+	for ; con.handlerIndex < len(con.handlers); con.handlerIndex++ {
 		con.handlers[con.handlerIndex](con)
 		if con.Opt.Stop {
+			con.handlerIndex = _ABORT_INDEX
 			return
 		}
 	}
 }
 
+// 复制 Context, 用于在其他 goroutine 中继续执行后续处理.
+// 请求使用 ctx, 响应写入 w, Var 与 Data 为浅复制, 模板渲染绑定原响应, 复制后不可用
+func (con *Context) Copy(ctx context.Context, w http.ResponseWriter) *Context {
+	cp := &Context{
+		Log:          con.Log,
+		Lang:         con.Lang,
+		Var:          make(map[string]interface{}, len(con.Var)),
+		data:         make(map[string]interface{}, len(con.data)),
+		handlers:     con.handlers,
+		handlerIndex: con.handlerIndex,
+	}
+	cp.Ren = &Render{con: cp}
+	cp.Opt = &contextOpts{Log: con.Opt.Log}
+	cp.resp.reset(w, cp)
+	cp.Resp = &cp.resp
+	cp.Req = con.Req
+	cp.Req.Request = con.Req.Request.Clone(ctx)
+	cp.Req.con = cp
+	for k, v := range con.Var {
+		cp.Var[k] = v
+	}
+	for k, v := range con.data {
+		cp.data[k] = v
+	}
+	return cp
+}
+
+// 停止执行后续处理, code 为 0 时不修改状态码
 func (con *Context) Abort(code int) {
 	con.resp.WriteHeader(code)
-	con.handlerIndex = math.MaxInt8 / 2
+	con.Opt.Stop = true
+	con.handlerIndex = _ABORT_INDEX
+}
+
+func (con *Context) AbortWithStatusJSON(code int, v interface{}) {
+	con.Abort(0)
+	con.Ren.JSON(code, v)
+}
+
+func (con *Context) AbortWithError(code int, err error) {
+	con.err = err
+	con.Abort(code)
+}
+
+func (con *Context) IsAborted() bool {
+	return con.Opt.Stop
+}
+
+func (con *Context) ErrorGet() error {
+	return con.err
 }
 
 // Context - Data
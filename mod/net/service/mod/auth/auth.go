@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sail-services/sail-go/com/data/convert"
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	Options struct {
+		Realm        string                                 // Basic 认证域 [Restricted]
+		Users        map[string]string                      // Basic 用户名 -> 密码 [nil]
+		BasicFunc    func(user, pass string) *Principal     // 自定义 Basic 校验 [nil]
+		TokenFunc    func(token string) *Principal          // Bearer Token 与 API Key 校验 [nil]
+		APIKeyHeader string                                 // API Key 的 Header 名 [X-API-Key]
+		JWTSecret    string                                 // HS256 密钥 [nil]
+		JWTPublicKey string                                 // RS256 PEM 公钥 [nil]
+		JWTKey       *rsa.PublicKey                         // 已解析的 RS256 公钥, 设置时忽略 JWTPublicKey [nil]
+		JWTAudience  string                                 // 要求的 aud [nil]
+		JWTLeeway    time.Duration                          // exp/nbf 允许的时间误差 [0]
+		Required     bool                                   // 未认证时直接返回 401 [false]
+		ErrorFunc    func(con *service.Context, status int) // 认证失败的处理 [文本]
+	}
+	Principal struct {
+		ID     string                 // 用户 ID (JWT: sub)
+		Name   string                 // 用户名
+		Roles  []string               // 角色 (JWT: roles/role)
+		Method string                 // 认证方式 basic/token/jwt
+		Claims map[string]interface{} // JWT 全部声明
+	}
+	auth struct {
+		opt    Options
+		pubKey *rsa.PublicKey
+	}
+)
+
+const (
+	METHOD_BASIC = "basic"
+	METHOD_TOKEN = "token"
+	METHOD_JWT   = "jwt"
+
+	_DATA_AUTH   = "_DATA_AUTH"
+	_DATA_AUTH_X = "_DATA_AUTH_X"
+)
+
+var (
+	ErrJWTFormat    = errors.New("auth: malformed jwt")
+	ErrJWTAlg       = errors.New("auth: unsupported jwt alg")
+	ErrJWTSignature = errors.New("auth: invalid jwt signature")
+	ErrJWTExpired   = errors.New("auth: jwt expired")
+	ErrJWTNotBefore = errors.New("auth: jwt not valid yet")
+	ErrJWTAudience  = errors.New("auth: jwt audience mismatch")
+	ErrJWTKey       = errors.New("auth: invalid rsa public key")
+)
+
+// 从 Authorization (Basic/Bearer) 或 API Key Header 中认证, 结果通过 DataGetPrincipal 获取
+func New(opts ...Options) service.Handler {
+	a, err := authNew(optPrepare(opts))
+	if err != nil {
+		panic(err)
+	}
+	return func(con *service.Context) {
+		con.DataSet(_DATA_AUTH_X, a)
+		if p := a.authenticate(con.Req.Request); p != nil {
+			con.DataSet(_DATA_AUTH, p)
+			return
+		}
+		if a.opt.Required {
+			a.fail(con, http.StatusUnauthorized)
+		}
+	}
+}
+
+// 未认证时返回 401
+func RequireAuth(con *service.Context) {
+	if DataGetPrincipal(con) == nil {
+		authGet(con).fail(con, http.StatusUnauthorized)
+	}
+}
+
+// 未认证返回 401, 不具有任一角色返回 403
+func RequireRole(roles ...string) service.Handler {
+	return func(con *service.Context) {
+		p := DataGetPrincipal(con)
+		if p == nil {
+			authGet(con).fail(con, http.StatusUnauthorized)
+			return
+		}
+		for _, role := range roles {
+			if p.HasRole(role) {
+				return
+			}
+		}
+		authGet(con).fail(con, http.StatusForbidden)
+	}
+}
+
+func DataGetPrincipal(con *service.Context) *Principal {
+	if p, ok := con.DataGet(_DATA_AUTH); ok {
+		return p.(*Principal)
+	}
+	return nil
+}
+
+// 校验 JWT, 成功返回其中的用户信息
+// 多次调用时应先用 convert.RSAPubKeySParse 解析公钥并设置到 JWTKey
+func JWTVerify(token string, opt Options) (*Principal, error) {
+	a, err := authNew(optPrepare([]Options{opt}))
+	if err != nil {
+		return nil, err
+	}
+	return a.jwtVerify(token, time.Now())
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Realm == "" {
+		opt.Realm = "Restricted"
+	}
+	if opt.APIKeyHeader == "" {
+		opt.APIKeyHeader = "X-API-Key"
+	}
+	if opt.ErrorFunc == nil {
+		opt.ErrorFunc = func(con *service.Context, status int) {
+			con.Ren.S(status, http.StatusText(status))
+		}
+	}
+	return opt
+}
+
+func authNew(opt Options) (*auth, error) {
+	a := &auth{opt: opt, pubKey: opt.JWTKey}
+	if a.pubKey == nil && opt.JWTPublicKey != "" {
+		key, err := convert.RSAPubKeySParse(opt.JWTPublicKey)
+		if err != nil {
+			return nil, ErrJWTKey
+		}
+		a.pubKey = key
+	}
+	return a, nil
+}
+
+func authGet(con *service.Context) *auth {
+	if a, ok := con.DataGet(_DATA_AUTH_X); ok {
+		return a.(*auth)
+	}
+	return &auth{opt: optPrepare(nil)}
+}
+
+// ========================================================
+// auth
+// ========================================================
+func (a *auth) authenticate(req *http.Request) *Principal {
+	hd := req.Header.Get("Authorization")
+	if i := strings.IndexByte(hd, ' '); i > 0 {
+		scheme, cred := strings.ToLower(hd[:i]), strings.TrimSpace(hd[i+1:])
+		switch scheme {
+		case "basic":
+			if user, pass, ok := req.BasicAuth(); ok {
+				return a.basic(user, pass)
+			}
+		case "bearer":
+			if strings.Count(cred, ".") == 2 && (a.opt.JWTSecret != "" || a.pubKey != nil) {
+				p, _ := a.jwtVerify(cred, time.Now())
+				return p
+			}
+			if a.opt.TokenFunc != nil {
+				return a.principal(a.opt.TokenFunc(cred), METHOD_TOKEN)
+			}
+		}
+	}
+	if key := req.Header.Get(a.opt.APIKeyHeader); key != "" && a.opt.TokenFunc != nil {
+		return a.principal(a.opt.TokenFunc(key), METHOD_TOKEN)
+	}
+	return nil
+}
+
+func (a *auth) basic(user, pass string) *Principal {
+	if a.opt.BasicFunc != nil {
+		return a.principal(a.opt.BasicFunc(user, pass), METHOD_BASIC)
+	}
+	ok := false
+	for u, p := range a.opt.Users {
+		if secureCompare(u, user) && secureCompare(p, pass) {
+			ok = true
+		}
+	}
+	if !ok {
+		return nil
+	}
+	return &Principal{ID: user, Name: user, Method: METHOD_BASIC}
+}
+
+func (a *auth) principal(p *Principal, method string) *Principal {
+	if p != nil && p.Method == "" {
+		p.Method = method
+	}
+	return p
+}
+
+func (a *auth) fail(con *service.Context, status int) {
+	if status == http.StatusUnauthorized {
+		if len(a.opt.Users) > 0 || a.opt.BasicFunc != nil {
+			con.Resp.Header().Set("WWW-Authenticate", `Basic realm="`+a.opt.Realm+`"`)
+		} else {
+			con.Resp.Header().Set("WWW-Authenticate", `Bearer realm="`+a.opt.Realm+`"`)
+		}
+	}
+	con.Abort(status)
+	a.opt.ErrorFunc(con, status)
+}
+
+func (a *auth) jwtVerify(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTFormat
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := jwtDecode(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTFormat
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case header.Alg == "HS256" && a.opt.JWTSecret != "":
+		mac := hmac.New(sha256.New, []byte(a.opt.JWTSecret))
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, ErrJWTSignature
+		}
+	case header.Alg == "RS256" && a.pubKey != nil:
+		sum := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(a.pubKey, crypto.SHA256, sum[:], sig) != nil {
+			return nil, ErrJWTSignature
+		}
+	default:
+		return nil, ErrJWTAlg
+	}
+	claims := make(map[string]interface{})
+	if err = jwtDecode(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(a.opt.JWTLeeway)) {
+		return nil, ErrJWTExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.opt.JWTLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrJWTNotBefore
+	}
+	if a.opt.JWTAudience != "" && !audienceHas(claims["aud"], a.opt.JWTAudience) {
+		return nil, ErrJWTAudience
+	}
+	p := &Principal{Method: METHOD_JWT, Claims: claims}
+	p.ID, _ = claims["sub"].(string)
+	p.Name, _ = claims["name"].(string)
+	if role, ok := claims["role"].(string); ok {
+		p.Roles = append(p.Roles, role)
+	}
+	if roles, ok := claims["roles"].([]interface{}); ok {
+		for _, r := range roles {
+			if role, ok := r.(string); ok {
+				p.Roles = append(p.Roles, role)
+			}
+		}
+	}
+	return p, nil
+}
+
+// ========================================================
+// Principal
+// ========================================================
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// --------------------------------------------------------
+// FUNC
+// --------------------------------------------------------
+func jwtDecode(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return ErrJWTFormat
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return ErrJWTFormat
+	}
+	return nil
+}
+
+func audienceHas(aud interface{}, want string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 比较前先取摘要, 避免长度不同时提前返回
+func secureCompare(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
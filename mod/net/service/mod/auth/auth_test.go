@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/com/data/convert"
+)
+
+func jwtSign(alg, claims string, sign func([]byte) []byte) string {
+	enc := base64.RawURLEncoding
+	s := enc.EncodeToString([]byte(`{"alg":"`+alg+`","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims))
+	return s + "." + enc.EncodeToString(sign([]byte(s)))
+}
+
+func Test_JWTHS256(t *testing.T) {
+	a := &auth{opt: optPrepare([]Options{{JWTSecret: "secret", JWTAudience: "api"}})}
+	hs := func(data []byte) []byte {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+	now := time.Unix(1000, 0)
+	p, err := a.jwtVerify(jwtSign("HS256", `{"sub":"42","aud":["api"],"exp":2000,"roles":["admin"]}`, hs), now)
+	if err != nil || p.ID != "42" || !p.HasRole("admin") {
+		t.Fatalf("valid token rejected: %v %+v", err, p)
+	}
+	if _, err = a.jwtVerify(jwtSign("HS256", `{"sub":"42","aud":"api","exp":500}`, hs), now); err != ErrJWTExpired {
+		t.Errorf("expired token: %v", err)
+	}
+	if _, err = a.jwtVerify(jwtSign("HS256", `{"sub":"42","aud":"web"}`, hs), now); err != ErrJWTAudience {
+		t.Errorf("wrong audience: %v", err)
+	}
+	if _, err = a.jwtVerify(jwtSign("none", `{"sub":"42","aud":"api"}`, func([]byte) []byte { return nil }), now); err != ErrJWTAlg {
+		t.Errorf("alg none: %v", err)
+	}
+}
+
+func Test_JWTRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := convert.RSAPubKeySParse(convert.RSAPubKeyToS("PUBLIC KEY", &key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &auth{opt: optPrepare(nil), pubKey: pub}
+	rs := func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		return sig
+	}
+	if _, err = a.jwtVerify(jwtSign("RS256", `{"sub":"7","nbf":100}`, rs), time.Unix(200, 0)); err != nil {
+		t.Errorf("valid RS256 token rejected: %v", err)
+	}
+	if _, err = a.jwtVerify(jwtSign("RS256", `{"sub":"7","nbf":300}`, rs), time.Unix(200, 0)); err != ErrJWTNotBefore {
+		t.Errorf("nbf not checked: %v", err)
+	}
+	if _, err = JWTVerify("a.b.c", Options{JWTPublicKey: "not a pem"}); err != ErrJWTKey {
+		t.Errorf("bad pem: %v", err)
+	}
+}
+
+func Test_Basic(t *testing.T) {
+	a := &auth{opt: optPrepare([]Options{{Users: map[string]string{"admin": "pass"}}})}
+	if p := a.basic("admin", "pass"); p == nil || p.Method != METHOD_BASIC {
+		t.Errorf("valid basic credentials rejected")
+	}
+	if p := a.basic("admin", "wrong"); p != nil {
+		t.Errorf("invalid basic credentials accepted")
+	}
+}
@@ -1,7 +1,12 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
+
 	"github.com/sail-services/sail-go/mod/net/service"
 )
 
@@ -12,16 +17,24 @@ type (
 		Interval   int    // 数据回收间隔 [60]
 		OccupyMode bool   // Redis: Occupy entire database [false]
 	}
+	// 所有方法的 ttl 为 0 时不过期, tags 为该 Key 的标签, 用于 DeleteTag 批量失效
 	Cache interface {
-		Get(key string) interface{}                           // 获取
-		Put(key string, val interface{}, timeout int64) error // 追加
-		Delete(key string) error                              // 删除
-		Incr(key string) error                                // 储存的数字值 +1
-		Decr(key string) error                                // 储存的数字值 -1
-		IsExist(key string) bool                              // Key 是否存在
-		Flush() error                                         // 清空整个数据库
+		Get(ctx context.Context, key string) (Value, error)                                                    // 获取, 不存在时返回 ErrNotFound
+		GetMulti(ctx context.Context, keys []string) (map[string]Value, error)                                 // 批量获取, 结果只含存在的 Key
+		Set(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) error         // 设置
+		SetMulti(ctx context.Context, items map[string]interface{}, ttl time.Duration, tags ...string) error   // 批量设置
+		Add(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) (bool, error) // Key 不存在时设置, 返回是否已设置
+		Delete(ctx context.Context, key string) error                                                          // 删除
+		Incr(ctx context.Context, key string, delta int64) (int64, error)                                      // 数字值加 delta 并返回新值, Key 不存在时从 0 开始且不过期
+		Touch(ctx context.Context, key string, ttl time.Duration) error                                        // 重设过期时间, 不存在时返回 ErrNotFound
+		Exist(ctx context.Context, key string) (bool, error)                                                   // Key 是否存在
+		DeleteTag(ctx context.Context, tags ...string) error                                                   // 删除带有任一标签的全部 Key
+		Flush(ctx context.Context) error                                                                       // 清空整个数据库
 		StartAndGC(opt Options) error
 	}
+	statsCache struct {
+		Cache
+	}
 )
 
 const (
@@ -29,7 +42,12 @@ const (
 )
 
 var (
+	ErrNotFound  = errors.New("cache: key not found")
+	ErrNotNumber = errors.New("cache: value is not a number")
+
 	adapters = make(map[string]Cache)
+	hits     uint64
+	misses   uint64
 )
 
 func New(opts ...Options) service.Handler {
@@ -63,11 +81,39 @@ func cacheHandler(adapterName string, config Options) (service.Handler, error) {
 	if err := adapter.StartAndGC(config); err != nil {
 		return nil, err
 	}
+	c := &statsCache{adapter}
 	return func(con *service.Context) {
-		con.DataSet(_DATA_CACHE, adapter)
+		con.DataSet(_DATA_CACHE, c)
 	}, nil
 }
 
+// 通过 cache 模块读取的命中与未命中次数
+func StatsGet() (hit, miss uint64) {
+	return atomic.LoadUint64(&hits), atomic.LoadUint64(&misses)
+}
+
+// ========================================================
+// statsCache
+// ========================================================
+func (c *statsCache) Get(ctx context.Context, key string) (Value, error) {
+	val, err := c.Cache.Get(ctx, key)
+	if err == ErrNotFound {
+		atomic.AddUint64(&misses, 1)
+	} else if err == nil {
+		atomic.AddUint64(&hits, 1)
+	}
+	return val, err
+}
+
+func (c *statsCache) GetMulti(ctx context.Context, keys []string) (map[string]Value, error) {
+	vals, err := c.Cache.GetMulti(ctx, keys)
+	if err == nil {
+		atomic.AddUint64(&hits, uint64(len(vals)))
+		atomic.AddUint64(&misses, uint64(len(keys)-len(vals)))
+	}
+	return vals, err
+}
+
 func optPrepare(opts []Options) Options {
 	var opt Options
 	if len(opts) > 0 {
@@ -1,139 +1,527 @@
-package cache
+package memory
 
 import (
-	"errors"
-	"github.com/sail-services/sail-go/foundation/framework/service/cache"
+	"container/list"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
 )
 
 type MemoryItem struct {
-	val     interface{}
-	created int64
-	expire  int64
+	key    string
+	val    interface{}
+	expire time.Time // 零值为不过期
+	tags   []string
+	size   int64
+	slot   int // 时间轮的槽位, -1 为不在时间轮中
+
+	elem  *list.Element // LRU
+	freq  uint64        // LFU
+	seq   uint64        // LFU
+	index int           // LFU
 }
 
+// 按 Key 的哈希分片, 每个分片有独立的锁、淘汰策略与时间轮.
+// 数量与字节数限制平均分配到各分片, 超出时按策略淘汰, 单个值超出分片的字节数限制时不保存.
+// 过期的 Key 读取时视为不存在, 由时间轮每个 tick 清理到期槽位中的 Key.
 type MemoryCacher struct {
-	lock     sync.RWMutex
-	items    map[string]*MemoryItem
-	interval int
+	lock   sync.RWMutex // 保护 shards, StartAndGC 时整体替换
+	shards []*shard
+	tick   time.Duration
+	stop   chan struct{}
+}
+
+type MemoryStats struct {
+	Hits      uint64 // 命中次数
+	Misses    uint64 // 未命中次数
+	Evictions uint64 // 超出限制淘汰的数量
+	Expired   uint64 // 时间轮清理的过期数量
+	Entries   int    // 当前数量
+	Bytes     int64  // 当前估算字节数
+}
+
+type shard struct {
+	lock       sync.Mutex
+	kind       string
+	items      map[string]*MemoryItem
+	tags       map[string]map[string]struct{} // 标签 -> Key 集合
+	policy     policy
+	wheel      []map[string]struct{} // 槽位 -> Key 集合
+	tick       time.Duration
+	last       int64 // 已处理到的 tick
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+	stats      MemoryStats
+}
+
+// Conn: "shards=16,max_entries=0,max_bytes=67108864,policy=lru,tick=1s,slots=512"
+// max_entries 与 max_bytes 为 0 时不限制, 默认限制 64MB, 不需要限制时设置 max_bytes=0
+type memoryConfig struct {
+	shards     int
+	maxEntries int
+	maxBytes   int64
+	policy     string
+	tick       time.Duration
+	slots      int
 }
 
+const (
+	_SHARDS_DEFAULT    = 16
+	_MAX_BYTES_DEFAULT = 64 << 20
+	_TICK_DEFAULT      = time.Second
+	_SLOTS_DEFAULT     = 512
+)
+
 func init() {
 	cache.Register("memory", NewMemoryCacher())
 }
 
+// 使用默认配置 (最多 64MB), 时间轮在 StartAndGC 后开始转动
 func NewMemoryCacher() *MemoryCacher {
-	return &MemoryCacher{items: make(map[string]*MemoryItem)}
+	c := &MemoryCacher{}
+	c.configure(memoryConfigDefault())
+	return c
 }
 
-func (c *MemoryCacher) Put(key string, val interface{}, expire int64) error {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	c.items[key] = &MemoryItem{
-		val:     val,
-		created: time.Now().Unix(),
-		expire:  expire,
+func (c *MemoryCacher) Get(ctx context.Context, key string) (cache.Value, error) {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item := s.get(key, time.Now())
+	if item == nil {
+		return cache.Value{}, cache.ErrNotFound
+	}
+	return cache.ValueOf(item.val), nil
+}
+
+func (c *MemoryCacher) GetMulti(ctx context.Context, keys []string) (map[string]cache.Value, error) {
+	now := time.Now()
+	vals := make(map[string]cache.Value, len(keys))
+	for s, keys := range c.group(keys) {
+		s.lock.Lock()
+		for _, key := range keys {
+			if item := s.get(key, now); item != nil {
+				vals[key] = cache.ValueOf(item.val)
+			}
+		}
+		s.lock.Unlock()
 	}
+	return vals, nil
+}
+
+func (c *MemoryCacher) Set(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) error {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.set(key, val, ttl, tags)
 	return nil
 }
 
-func (c *MemoryCacher) Get(key string) interface{} {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-	item, ok := c.items[key]
-	if !ok {
-		return nil
+func (c *MemoryCacher) SetMulti(ctx context.Context, items map[string]interface{}, ttl time.Duration, tags ...string) error {
+	for key, val := range items {
+		c.Set(ctx, key, val, ttl, tags...)
 	}
-	if item.hasExpired() {
-		go c.Delete(key)
-		return nil
+	return nil
+}
+
+func (c *MemoryCacher) Add(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) (bool, error) {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if item, ok := s.items[key]; ok && !item.hasExpired(time.Now()) {
+		return false, nil
+	}
+	s.set(key, val, ttl, tags)
+	return true, nil
+}
+
+func (c *MemoryCacher) Delete(ctx context.Context, key string) error {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.delete(key)
+	return nil
+}
+
+func (c *MemoryCacher) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, ok := s.items[key]
+	if !ok || item.hasExpired(time.Now()) {
+		s.set(key, delta, 0, nil)
+		return delta, nil
+	}
+	n, err := cache.ValueOf(item.val).Int64()
+	if err != nil {
+		return 0, cache.ErrNotNumber
+	}
+	n += delta
+	s.bytes += sizeOf(key, n) - item.size
+	item.val, item.size = n, sizeOf(key, n)
+	s.policy.access(item)
+	s.evict(0, 0)
+	return n, nil
+}
+
+func (c *MemoryCacher) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, ok := s.items[key]
+	if !ok || item.hasExpired(time.Now()) {
+		return cache.ErrNotFound
+	}
+	s.unschedule(item)
+	item.expire = expireAt(ttl)
+	s.schedule(item)
+	return nil
+}
+
+func (c *MemoryCacher) Exist(ctx context.Context, key string) (bool, error) {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, ok := s.items[key]
+	return ok && !item.hasExpired(time.Now()), nil
+}
+
+func (c *MemoryCacher) DeleteTag(ctx context.Context, tags ...string) error {
+	for _, s := range c.shardsGet() {
+		s.lock.Lock()
+		for _, tag := range tags {
+			for key := range s.tags[tag] {
+				s.delete(key)
+			}
+			delete(s.tags, tag)
+		}
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+func (c *MemoryCacher) Flush(ctx context.Context) error {
+	for _, s := range c.shardsGet() {
+		s.lock.Lock()
+		s.reset()
+		s.lock.Unlock()
 	}
-	return item.val
+	return nil
 }
 
-func (c *MemoryCacher) Delete(key string) error {
+// Conn 见 memoryConfig, 重新配置时清空已有数据
+func (c *MemoryCacher) StartAndGC(opt cache.Options) error {
+	cfg, err := memoryConfigParse(opt.Conn)
+	if err != nil {
+		return err
+	}
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	delete(c.items, key)
+	if c.stop != nil {
+		close(c.stop)
+	}
+	c.configure(cfg)
+	c.stop = make(chan struct{})
+	go run(c.stop, c.shards, c.tick)
 	return nil
 }
 
-func (c *MemoryCacher) Incr(key string) (err error) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+// 各分片统计的合计
+func (c *MemoryCacher) Stats() MemoryStats {
+	var total MemoryStats
+	for _, s := range c.shardsGet() {
+		s.lock.Lock()
+		st := s.stats
+		st.Entries, st.Bytes = len(s.items), s.bytes
+		s.lock.Unlock()
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Evictions += st.Evictions
+		total.Expired += st.Expired
+		total.Entries += st.Entries
+		total.Bytes += st.Bytes
+	}
+	return total
+}
 
-	item, ok := c.items[key]
-	if !ok {
-		return errors.New("key not exist")
+func (c *MemoryCacher) configure(cfg memoryConfig) {
+	shards := make([]*shard, cfg.shards)
+	for i := range shards {
+		shards[i] = &shard{
+			kind:       cfg.policy,
+			wheel:      make([]map[string]struct{}, cfg.slots),
+			tick:       cfg.tick,
+			last:       time.Now().UnixNano() / int64(cfg.tick),
+			maxEntries: (cfg.maxEntries + cfg.shards - 1) / cfg.shards,
+			maxBytes:   (cfg.maxBytes + int64(cfg.shards) - 1) / int64(cfg.shards),
+		}
+		shards[i].reset()
 	}
-	item.val, err = cache.Incr(item.val)
-	return err
+	c.shards, c.tick = shards, cfg.tick
 }
 
-func (c *MemoryCacher) Decr(key string) (err error) {
+// 各分片的时间轮转到 now
+func (c *MemoryCacher) advance(now time.Time) {
+	advance(c.shardsGet(), now)
+}
+
+// 重新配置前取得的分片仍可安全使用, 只是其中的数据不再可见
+func (c *MemoryCacher) shardsGet() []*shard {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
-	item, ok := c.items[key]
-	if !ok {
-		return errors.New("key not exist")
+	return c.shards
+}
+
+func (c *MemoryCacher) shard(key string) *shard {
+	return shardOf(c.shardsGet(), key)
+}
+
+func (c *MemoryCacher) group(keys []string) map[*shard][]string {
+	shards := c.shardsGet()
+	groups := make(map[*shard][]string)
+	for _, key := range keys {
+		s := shardOf(shards, key)
+		groups[s] = append(groups[s], key)
 	}
-	item.val, err = cache.Decr(item.val)
-	return err
+	return groups
 }
 
-func (c *MemoryCacher) IsExist(key string) bool {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+func shardOf(shards []*shard, key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return shards[h.Sum32()%uint32(len(shards))]
+}
+
+// ========================================================
+// shard
+// ========================================================
+// 以下方法调用者需持有分片的锁
 
-	_, ok := c.items[key]
-	return ok
+func (s *shard) reset() {
+	s.items = make(map[string]*MemoryItem)
+	s.tags = make(map[string]map[string]struct{})
+	for i := range s.wheel {
+		s.wheel[i] = nil
+	}
+	s.policy = policyNew(s.kind)
+	s.bytes = 0
 }
 
-func (c *MemoryCacher) Flush() error {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	c.items = make(map[string]*MemoryItem)
-	return nil
+func (s *shard) get(key string, now time.Time) *MemoryItem {
+	item, ok := s.items[key]
+	if !ok || item.hasExpired(now) {
+		s.stats.Misses++
+		return nil
+	}
+	s.stats.Hits++
+	s.policy.access(item)
+	return item
+}
+
+func (s *shard) set(key string, val interface{}, ttl time.Duration, tags []string) {
+	s.delete(key)
+	item := &MemoryItem{key: key, val: val, expire: expireAt(ttl), tags: tags, size: sizeOf(key, val), slot: -1}
+	if s.maxBytes > 0 && item.size > s.maxBytes {
+		return
+	}
+	// 先淘汰再加入, 否则 LFU 下新的 Key 总是被立即淘汰
+	s.evict(1, item.size)
+	s.items[key] = item
+	s.bytes += item.size
+	s.policy.push(item)
+	s.schedule(item)
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
 }
 
-func (c *MemoryCacher) checkRawExpiration(key string) {
-	item, ok := c.items[key]
+func (s *shard) delete(key string) {
+	item, ok := s.items[key]
 	if !ok {
 		return
 	}
-	if item.hasExpired() {
-		delete(c.items, key)
+	delete(s.items, key)
+	s.bytes -= item.size
+	s.policy.remove(item)
+	s.unschedule(item)
+	for _, tag := range item.tags {
+		delete(s.tags[tag], key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
 	}
 }
 
-func (c *MemoryCacher) checkExpiration(key string) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	c.checkRawExpiration()
+// evict 淘汰直到再加入 n 个共 size 字节后不超出限制
+func (s *shard) evict(n int, size int64) {
+	for s.maxEntries > 0 && len(s.items)+n > s.maxEntries || s.maxBytes > 0 && s.bytes+size > s.maxBytes {
+		item := s.policy.victim()
+		if item == nil {
+			return
+		}
+		s.delete(item.key)
+		s.stats.Evictions++
+	}
+}
+
+// schedule 将有过期时间的 Key 放入过期时所在 tick 的槽位, 超过一圈的 Key 在经过槽位时保留
+func (s *shard) schedule(item *MemoryItem) {
+	if item.expire.IsZero() {
+		return
+	}
+	item.slot = int(s.tickOf(item.expire) % int64(len(s.wheel)))
+	if s.wheel[item.slot] == nil {
+		s.wheel[item.slot] = make(map[string]struct{})
+	}
+	s.wheel[item.slot][item.key] = struct{}{}
 }
 
-func (c *MemoryCacher) startGC() {
-	if c.interval < 1 {
+func (s *shard) unschedule(item *MemoryItem) {
+	if item.slot < 0 {
 		return
 	}
-	if c.items != nil {
-		c.lock.Lock()
-		defer c.lock.Unlock()
-		for key, _ := range c.items {
-			c.checkRawExpiration(key)
+	delete(s.wheel[item.slot], item.key)
+	item.slot = -1
+}
+
+// advance 处理上次之后到 now 的槽位, 间隔超过一圈时只处理一圈
+func (s *shard) advance(now time.Time) {
+	cur := now.UnixNano() / int64(s.tick)
+	from := s.last + 1
+	if cur-from >= int64(len(s.wheel)) {
+		from = cur - int64(len(s.wheel)) + 1
+	}
+	for t := from; t <= cur; t++ {
+		for key := range s.wheel[t%int64(len(s.wheel))] {
+			if item := s.items[key]; item.hasExpired(now) {
+				s.delete(key)
+				s.stats.Expired++
+			}
 		}
 	}
-	time.AfterFunc(time.Duration(c.interval)*time.Second, func() { c.startGC() })
+	if cur > s.last {
+		s.last = cur
+	}
 }
 
-func (c *MemoryCacher) StartAndGC(opt cache.Options) error {
-	c.interval = opt.Interval
-	go c.startGC()
-	return nil
+// 过期时间向上取整到 tick, 确保处理槽位时已过期
+func (s *shard) tickOf(t time.Time) int64 {
+	return (t.UnixNano() + int64(s.tick) - 1) / int64(s.tick)
+}
+
+// run 按 tick 转动时间轮直到 stop 关闭, 重新配置后旧的分片由旧的 goroutine 处理至停止
+func run(stop chan struct{}, shards []*shard, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			advance(shards, now)
+		}
+	}
+}
+
+func advance(shards []*shard, now time.Time) {
+	for _, s := range shards {
+		s.lock.Lock()
+		s.advance(now)
+		s.lock.Unlock()
+	}
+}
+
+func (item *MemoryItem) hasExpired(now time.Time) bool {
+	return !item.expire.IsZero() && !now.Before(item.expire)
 }
 
-func (item *MemoryItem) hasExpired() bool {
-	return item.expire > 0 &&
-		(time.Now().Unix()-item.created) >= item.expire
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// sizeOf 估算 Key 与值占用的字节数
+func sizeOf(key string, val interface{}) int64 {
+	n := int64(len(key))
+	switch v := val.(type) {
+	case string:
+		return n + int64(len(v))
+	case []byte:
+		return n + int64(len(v))
+	case bool, int8, uint8:
+		return n + 1
+	case int, int16, int32, int64, uint, uint16, uint32, uint64, float32, float64:
+		return n + 8
+	}
+	data, _ := cache.Encode(val)
+	return n + int64(len(data))
+}
+
+func memoryConfigDefault() memoryConfig {
+	return memoryConfig{
+		shards:   _SHARDS_DEFAULT,
+		maxBytes: _MAX_BYTES_DEFAULT,
+		policy:   POLICY_LRU,
+		tick:     _TICK_DEFAULT,
+		slots:    _SLOTS_DEFAULT,
+	}
+}
+
+func memoryConfigParse(conn string) (memoryConfig, error) {
+	cfg := memoryConfigDefault()
+	for _, kv := range strings.Split(conn, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		p := strings.SplitN(kv, "=", 2)
+		if len(p) != 2 {
+			return cfg, fmt.Errorf("cache/memory: invalid option '%s'", kv)
+		}
+		k, v := strings.TrimSpace(p[0]), strings.TrimSpace(p[1])
+		var err error
+		switch k {
+		case "shards":
+			cfg.shards, err = strconv.Atoi(v)
+		case "max_entries":
+			cfg.maxEntries, err = strconv.Atoi(v)
+		case "max_bytes":
+			cfg.maxBytes, err = strconv.ParseInt(v, 10, 64)
+		case "policy":
+			cfg.policy = v
+		case "tick":
+			cfg.tick, err = time.ParseDuration(v)
+		case "slots":
+			cfg.slots, err = strconv.Atoi(v)
+		default:
+			return cfg, fmt.Errorf("cache/memory: unsupported option '%s'", k)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("cache/memory: error parsing %s: %v", k, err)
+		}
+	}
+	switch {
+	case cfg.shards < 1:
+		return cfg, fmt.Errorf("cache/memory: shards must be positive")
+	case cfg.maxEntries < 0 || cfg.maxBytes < 0:
+		return cfg, fmt.Errorf("cache/memory: limits must not be negative")
+	case policyNew(cfg.policy) == nil:
+		return cfg, fmt.Errorf("cache/memory: unknown policy '%s'", cfg.policy)
+	case cfg.tick <= 0 || cfg.slots < 1:
+		return cfg, fmt.Errorf("cache/memory: tick and slots must be positive")
+	}
+	return cfg, nil
 }
@@ -0,0 +1,239 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func Test_Memory(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCacher()
+	if _, err := c.Get(ctx, "a"); err != cache.ErrNotFound {
+		t.Fatalf("Get of missing key: %v", err)
+	}
+	c.Set(ctx, "a", "1", 0)
+	c.Set(ctx, "u", user{"alice", 30}, time.Minute)
+	if v, _ := c.Get(ctx, "a"); v.String() != "1" {
+		t.Errorf("a = %q", v.String())
+	}
+	var u user
+	if v, _ := c.Get(ctx, "u"); v.Decode(&u) != nil || u.Name != "alice" {
+		t.Errorf("u = %+v", u)
+	}
+	if n, err := c.Incr(ctx, "a", 2); err != nil || n != 3 {
+		t.Errorf("Incr = %v, %v", n, err)
+	}
+	if n, err := c.Incr(ctx, "n", -1); err != nil || n != -1 {
+		t.Errorf("Incr of missing key = %v, %v", n, err)
+	}
+	if _, err := c.Incr(ctx, "u", 1); err != cache.ErrNotNumber {
+		t.Errorf("Incr of struct: %v", err)
+	}
+	if ok, _ := c.Add(ctx, "a", "x", 0); ok {
+		t.Errorf("Add of existing key succeeded")
+	}
+	if ok, _ := c.Add(ctx, "b", "x", 0); !ok {
+		t.Errorf("Add of missing key failed")
+	}
+	vals, _ := c.GetMulti(ctx, []string{"a", "b", "c"})
+	if len(vals) != 2 {
+		t.Errorf("GetMulti = %v", vals)
+	}
+}
+
+func Test_MemoryExpire(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCacher()
+	c.Set(ctx, "a", 1, 20*time.Millisecond)
+	if err := c.Touch(ctx, "missing", time.Second); err != cache.ErrNotFound {
+		t.Errorf("Touch of missing key: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if ok, _ := c.Exist(ctx, "a"); ok {
+		t.Errorf("expired key exists")
+	}
+	c.Set(ctx, "b", 1, 20*time.Millisecond)
+	c.Touch(ctx, "b", 0)
+	time.Sleep(30 * time.Millisecond)
+	if ok, _ := c.Exist(ctx, "b"); !ok {
+		t.Errorf("key touched without expiry was removed")
+	}
+}
+
+func Test_MemoryTag(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCacher()
+	c.SetMulti(ctx, map[string]interface{}{"a": 1, "b": 2}, 0, "user:1")
+	c.Set(ctx, "c", 3, 0, "user:2", "user:1")
+	c.Set(ctx, "d", 4, 0, "user:2")
+	// 覆盖后不再带有旧标签
+	c.Set(ctx, "b", 5, 0)
+	if err := c.DeleteTag(ctx, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{"a": false, "b": true, "c": false, "d": true} {
+		if ok, _ := c.Exist(ctx, key); ok != want {
+			t.Errorf("Exist(%s) = %v, want %v", key, ok, want)
+		}
+	}
+	c.DeleteTag(ctx, "user:2")
+	if ok, _ := c.Exist(ctx, "d"); ok {
+		t.Errorf("d exists after DeleteTag")
+	}
+	for _, s := range c.shards {
+		if len(s.tags) != 0 {
+			t.Errorf("tags left: %v", s.tags)
+		}
+	}
+}
+
+func newTestCacher(conn string) *MemoryCacher {
+	cfg, err := memoryConfigParse(conn)
+	if err != nil {
+		panic(err)
+	}
+	c := &MemoryCacher{}
+	c.configure(cfg)
+	return c
+}
+
+func Test_MemoryLRU(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCacher("shards=1,max_entries=2")
+	c.Set(ctx, "a", 1, 0)
+	c.Set(ctx, "b", 2, 0)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", 3, 0)
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if ok, _ := c.Exist(ctx, key); ok != want {
+			t.Errorf("Exist(%s) = %v, want %v", key, ok, want)
+		}
+	}
+	if st := c.Stats(); st.Evictions != 1 || st.Entries != 2 || st.Hits != 1 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+func Test_MemoryLFU(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCacher("shards=1,max_entries=2,policy=lfu")
+	c.Set(ctx, "a", 1, 0)
+	c.Set(ctx, "b", 2, 0)
+	c.Get(ctx, "a")
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	c.Set(ctx, "c", 3, 0)
+	c.Get(ctx, "c")
+	c.Set(ctx, "d", 4, 0)
+	for key, want := range map[string]bool{"a": true, "b": false, "c": false, "d": true} {
+		if ok, _ := c.Exist(ctx, key); ok != want {
+			t.Errorf("Exist(%s) = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+// 过去的热点 a 在访问次数减半后被近期的 b 超过
+func Test_MemoryLFUAging(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCacher("shards=1,max_entries=2,policy=lfu")
+	c.Set(ctx, "a", 1, 0)
+	c.Set(ctx, "b", 2, 0)
+	for i := 0; i < 100; i++ {
+		c.Get(ctx, "a")
+	}
+	for i := 0; i < 40; i++ {
+		c.Get(ctx, "b")
+	}
+	c.Set(ctx, "c", 3, 0)
+	for key, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if ok, _ := c.Exist(ctx, key); ok != want {
+			t.Errorf("Exist(%s) = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func Test_MemoryMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCacher("shards=1,max_bytes=10")
+	c.Set(ctx, "a", "1234", 0)
+	c.Set(ctx, "b", "1234", 0)
+	if st := c.Stats(); st.Entries != 2 || st.Bytes != 10 {
+		t.Errorf("Stats = %+v", st)
+	}
+	c.Set(ctx, "c", "1", 0)
+	if ok, _ := c.Exist(ctx, "a"); ok {
+		t.Errorf("a not evicted")
+	}
+	c.Set(ctx, "big", "12345678901", 0)
+	if ok, _ := c.Exist(ctx, "big"); ok {
+		t.Errorf("value larger than limit was kept")
+	}
+}
+
+func Test_MemoryWheel(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCacher("shards=2,tick=10ms,slots=4")
+	c.Set(ctx, "a", 1, 15*time.Millisecond)
+	c.Set(ctx, "b", 1, 100*time.Millisecond)
+	c.Set(ctx, "c", 1, 0)
+	c.advance(time.Now())
+	if st := c.Stats(); st.Expired != 0 {
+		t.Errorf("wheel removed before expiry: %+v", st)
+	}
+	time.Sleep(30 * time.Millisecond)
+	c.advance(time.Now())
+	if st := c.Stats(); st.Expired != 1 || st.Entries != 2 {
+		t.Errorf("Stats after first expiry = %+v", st)
+	}
+	// b 的槽位经过一圈后才到期
+	time.Sleep(100 * time.Millisecond)
+	c.advance(time.Now())
+	if st := c.Stats(); st.Expired != 2 || st.Entries != 1 {
+		t.Errorf("Stats after second expiry = %+v", st)
+	}
+}
+
+// 重新配置与读写并发进行
+func Test_MemoryReconfigure(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCacher()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			c.Set(ctx, "a", i, 0)
+			c.Get(ctx, "a")
+			c.GetMulti(ctx, []string{"a", "b"})
+			c.Stats()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := c.StartAndGC(cache.Options{Conn: "shards=4,tick=10ms"}); err != nil {
+			t.Fatalf("StartAndGC: %v", err)
+		}
+	}
+	<-done
+	close(c.stop)
+}
+
+func Test_MemoryConfig(t *testing.T) {
+	if cfg, _ := memoryConfigParse(""); cfg.maxBytes != 64<<20 || cfg.maxEntries != 0 {
+		t.Errorf("default limits = %d entries, %d bytes", cfg.maxEntries, cfg.maxBytes)
+	}
+	if cfg, _ := memoryConfigParse("max_bytes=0"); cfg.maxBytes != 0 {
+		t.Errorf("max_bytes=0 = %d bytes, want unlimited", cfg.maxBytes)
+	}
+	for _, conn := range []string{"shards=0", "policy=fifo", "max_bytes=-1", "size=1", "tick"} {
+		if _, err := memoryConfigParse(conn); err == nil {
+			t.Errorf("memoryConfigParse(%q) succeeded", conn)
+		}
+	}
+}
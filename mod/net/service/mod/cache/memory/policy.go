@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+const (
+	POLICY_LRU = "lru" // 淘汰最久未访问的
+	POLICY_LFU = "lfu" // 淘汰访问次数最少的, 次数相同时淘汰最久未访问的
+)
+
+// 分片超出限制时选择淘汰的 Key, 调用者需持有分片的锁
+type policy interface {
+	push(item *MemoryItem)   // 新增
+	access(item *MemoryItem) // 读取或覆盖
+	remove(item *MemoryItem) // 删除
+	victim() *MemoryItem     // 下一个淘汰的, 为空时返回 nil
+}
+
+func policyNew(name string) policy {
+	switch name {
+	case POLICY_LRU:
+		return &lruPolicy{list.New()}
+	case POLICY_LFU:
+		return &lfuPolicy{}
+	}
+	return nil
+}
+
+// ========================================================
+// LRU
+// ========================================================
+// 链表头为最近访问的
+type lruPolicy struct {
+	l *list.List
+}
+
+func (p *lruPolicy) push(item *MemoryItem) {
+	item.elem = p.l.PushFront(item)
+}
+
+func (p *lruPolicy) access(item *MemoryItem) {
+	p.l.MoveToFront(item.elem)
+}
+
+func (p *lruPolicy) remove(item *MemoryItem) {
+	p.l.Remove(item.elem)
+	item.elem = nil
+}
+
+func (p *lruPolicy) victim() *MemoryItem {
+	if e := p.l.Back(); e != nil {
+		return e.Value.(*MemoryItem)
+	}
+	return nil
+}
+
+// ========================================================
+// LFU
+// ========================================================
+// 以访问次数与最后访问序号为序的最小堆.
+// 访问累计达到 Key 数量的 _LFU_AGING 倍时所有访问次数减半, 使过去的热点逐渐被淘汰
+type lfuPolicy struct {
+	items lfuHeap
+	seq   uint64
+	ops   int // 上次减半后的访问次数
+}
+
+const _LFU_AGING = 8
+
+type lfuHeap []*MemoryItem
+
+func (p *lfuPolicy) push(item *MemoryItem) {
+	p.seq++
+	item.freq, item.seq = 1, p.seq
+	heap.Push(&p.items, item)
+}
+
+func (p *lfuPolicy) access(item *MemoryItem) {
+	p.seq++
+	item.freq++
+	item.seq = p.seq
+	heap.Fix(&p.items, item.index)
+	if p.ops++; p.ops >= _LFU_AGING*len(p.items) {
+		p.age()
+	}
+}
+
+// age 访问次数减半, 最少保留 1, 减半后次数可能相同, 需重建堆
+func (p *lfuPolicy) age() {
+	for _, item := range p.items {
+		item.freq = (item.freq + 1) / 2
+	}
+	heap.Init(&p.items)
+	p.ops = 0
+}
+
+func (p *lfuPolicy) remove(item *MemoryItem) {
+	heap.Remove(&p.items, item.index)
+}
+
+func (p *lfuPolicy) victim() *MemoryItem {
+	if len(p.items) == 0 {
+		return nil
+	}
+	return p.items[0]
+}
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	item := x.(*MemoryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	PageOptions struct {
+		Stale time.Duration // 过期后仍可返回旧数据并后台更新的时长 [0]
+	}
+	pageEntry struct {
+		Status  int
+		Header  http.Header
+		Body    []byte
+		Vary    []string
+		Created int64
+	}
+	pageResponse struct {
+		service.Response
+		buf      *bytes.Buffer
+		header   http.Header
+		status   int
+		detached bool
+	}
+	pageCall struct {
+		wg    sync.WaitGroup
+		entry *pageEntry
+		vary  []string // 生成数据的请求得到的 Vary
+		key   string   // 生成数据的请求保存的 key
+	}
+	pageFlight struct {
+		lock  sync.Mutex
+		calls map[string]*pageCall
+	}
+	// 后台重新生成时的响应, 不发送给任何客户端
+	pageDiscard struct {
+		header http.Header
+	}
+)
+
+const (
+	_PAGE_PREFIX = "_PAGE_"
+)
+
+var (
+	// 每个请求不同的 Header, 不保存到缓存
+	pageSkipHeaders = map[string]bool{
+		"Set-Cookie":   true,
+		"Date":         true,
+		"X-Request-Id": true,
+		"Age":          true,
+		"X-Cache":      true,
+	}
+)
+
+// 缓存整个响应 (状态码, Header, Body), 需先加载 cache 模块
+// key 为 nil 时使用 Method + RequestURI
+func Page(ttl time.Duration, key func(*service.Context) string, opts ...PageOptions) service.Handler {
+	var opt PageOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if key == nil {
+		key = func(con *service.Context) string {
+			return con.Req.Method + " " + con.Req.URL.RequestURI()
+		}
+	}
+	flight := &pageFlight{calls: make(map[string]*pageCall)}
+	timeout := ttl + opt.Stale
+	return func(con *service.Context) {
+		if con.Req.Method != "GET" && con.Req.Method != "HEAD" {
+			return
+		}
+		cc := con.Req.Header.Get("Cache-Control")
+		if strings.Contains(cc, "no-store") {
+			return
+		}
+		c := DataGetCache(con)
+		base := _PAGE_PREFIX + key(con)
+		k := base
+		var entry *pageEntry
+		if !strings.Contains(cc, "no-cache") {
+			entry, k = pageGet(con.Req.Context(), c, base, con.Req.Request)
+		}
+		if entry != nil {
+			age := time.Since(time.Unix(0, entry.Created))
+			if age < ttl {
+				pageWrite(con, entry, age, "HIT")
+				con.Abort(0)
+				return
+			}
+			if age < ttl+opt.Stale {
+				call, leader := flight.begin(k)
+				pageWrite(con, entry, age, "STALE")
+				if leader {
+					pageRevalidate(con, flight, call, c, base, k, timeout)
+				}
+				con.Abort(0)
+				return
+			}
+		}
+		call, leader := flight.begin(k)
+		if !leader {
+			call.wg.Wait()
+			// 冷启动时 k 为 base, 生成数据的请求可能是另一个 Vary 变体
+			if call.entry != nil && base+pageVaryKey(call.vary, con.Req.Request) == call.key {
+				pageWrite(con, call.entry, 0, "HIT")
+				con.Abort(0)
+				return
+			}
+			pageRun(con, c, base, k, timeout, false)
+			con.Abort(0)
+			return
+		}
+		flight.run(k, call, func() (*pageEntry, string) {
+			return pageRun(con, c, base, k, timeout, false)
+		})
+	}
+}
+
+// 复制 Context 后在新的 goroutine 中重新生成数据, 不占用当前请求, 也不受客户端连接关闭的影响
+func pageRevalidate(con *service.Context, flight *pageFlight, call *pageCall, c Cache, base, k string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(con.Req.Context()), timeout)
+	bg := con.Copy(ctx, pageDiscard{make(http.Header)})
+	go func() {
+		defer cancel()
+		defer func() {
+			if err := recover(); err != nil {
+				bg.Log.Errorf("Page revalidate %v: %v\n", k, err)
+			}
+		}()
+		flight.run(k, call, func() (*pageEntry, string) {
+			return pageRun(bg, c, base, k, timeout, true)
+		})
+	}()
+}
+
+// 执行后续处理并记录响应, 返回保存的数据与 key, detached 时响应不再发送给客户端
+func pageRun(con *service.Context, c Cache, base, k string, timeout time.Duration, detached bool) (*pageEntry, string) {
+	resp := con.Resp
+	pr := &pageResponse{Response: resp, buf: new(bytes.Buffer), status: http.StatusOK, detached: detached}
+	if detached {
+		pr.header = make(http.Header)
+	}
+	con.Resp = pr
+	con.Next()
+	con.Resp = resp
+	if !detached {
+		pr.status = resp.Status()
+	}
+	hd := pr.Header()
+	if pr.status != http.StatusOK || !pageCacheable(hd) {
+		return nil, k
+	}
+	entry := &pageEntry{
+		Status:  pr.status,
+		Header:  make(http.Header),
+		Body:    pr.buf.Bytes(),
+		Created: time.Now().UnixNano(),
+	}
+	for name, vals := range hd {
+		if !pageSkipHeaders[name] {
+			entry.Header[name] = append([]string(nil), vals...)
+		}
+	}
+	vary := pageVary(hd)
+	if len(vary) > 0 {
+		entry.Vary = vary
+		pagePut(con.Req.Context(), c, base, &pageEntry{Vary: vary}, timeout)
+		k = base + pageVaryKey(vary, con.Req.Request)
+	}
+	pagePut(con.Req.Context(), c, k, entry, timeout)
+	return entry, k
+}
+
+func pageGet(ctx context.Context, c Cache, base string, req *http.Request) (*pageEntry, string) {
+	entry := pageDecode(c.Get(ctx, base))
+	if entry == nil || len(entry.Vary) == 0 {
+		return entry, base
+	}
+	k := base + pageVaryKey(entry.Vary, req)
+	return pageDecode(c.Get(ctx, k)), k
+}
+
+func pagePut(ctx context.Context, c Cache, k string, entry *pageEntry, timeout time.Duration) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(entry); err != nil {
+		return
+	}
+	c.Set(ctx, k, buf.Bytes(), timeout)
+}
+
+func pageDecode(val Value, err error) *pageEntry {
+	if err != nil {
+		return nil
+	}
+	data := val.Bytes()
+	if len(data) == 0 {
+		return nil
+	}
+	entry := new(pageEntry)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(entry); err != nil {
+		return nil
+	}
+	return entry
+}
+
+func pageWrite(con *service.Context, entry *pageEntry, age time.Duration, state string) {
+	hd := con.Resp.Header()
+	for name, vals := range entry.Header {
+		hd[name] = vals
+	}
+	hd.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	hd.Set("X-Cache", state)
+	con.Resp.WriteHeader(entry.Status)
+	con.Resp.Write(entry.Body)
+}
+
+func pageCacheable(hd http.Header) bool {
+	cc := hd.Get("Cache-Control")
+	return !strings.Contains(cc, "no-store") &&
+		!strings.Contains(cc, "no-cache") &&
+		!strings.Contains(cc, "private") &&
+		strings.TrimSpace(hd.Get("Vary")) != "*"
+}
+
+func pageVary(hd http.Header) []string {
+	var vary []string
+	for _, v := range hd["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary
+}
+
+func pageVaryKey(vary []string, req *http.Request) string {
+	k := ""
+	for _, name := range vary {
+		k += "|" + name + "=" + req.Header.Get(name)
+	}
+	return k
+}
+
+// ========================================================
+// pageFlight
+// ========================================================
+// 同一 key 只有一个请求生成数据, 其余请求等待结果
+func (f *pageFlight) begin(k string) (*pageCall, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if call, ok := f.calls[k]; ok {
+		return call, false
+	}
+	call := new(pageCall)
+	call.wg.Add(1)
+	f.calls[k] = call
+	return call, true
+}
+
+// run 执行 fn 生成数据后结束 call, fn panic 时也结束, 等待的请求各自生成数据
+func (f *pageFlight) run(k string, call *pageCall, fn func() (*pageEntry, string)) {
+	var entry *pageEntry
+	saved := k
+	defer func() {
+		f.end(k, call, entry, saved)
+	}()
+	entry, saved = fn()
+}
+
+// saved 为生成的数据保存的 key, 有 Vary 时与 k 不同
+func (f *pageFlight) end(k string, call *pageCall, entry *pageEntry, saved string) {
+	if entry != nil {
+		call.entry, call.vary, call.key = entry, entry.Vary, saved
+	}
+	f.lock.Lock()
+	delete(f.calls, k)
+	f.lock.Unlock()
+	call.wg.Done()
+}
+
+// ========================================================
+// pageResponse
+// ========================================================
+func (pr *pageResponse) Header() http.Header {
+	if pr.detached {
+		return pr.header
+	}
+	return pr.Response.Header()
+}
+
+func (pr *pageResponse) WriteHeader(code int) {
+	if pr.detached {
+		if code > 0 {
+			pr.status = code
+		}
+		return
+	}
+	pr.Response.WriteHeader(code)
+}
+
+func (pr *pageResponse) Write(p []byte) (int, error) {
+	pr.buf.Write(p)
+	if pr.detached {
+		return len(p), nil
+	}
+	return pr.Response.Write(p)
+}
+
+func (pr *pageResponse) Status() int {
+	if pr.detached {
+		return pr.status
+	}
+	return pr.Response.Status()
+}
+
+func (pr *pageResponse) Flush() {
+	if !pr.detached {
+		pr.Response.Flush()
+	}
+}
+
+// ========================================================
+// pageDiscard
+// ========================================================
+func (d pageDiscard) Header() http.Header {
+	return d.header
+}
+
+func (d pageDiscard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (d pageDiscard) WriteHeader(int) {}
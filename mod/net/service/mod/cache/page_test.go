@@ -0,0 +1,165 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache/memory"
+)
+
+var pageSeq int32
+
+// 每个测试使用新注册的 memory 适配器, 避免 -count 多次运行时共用数据
+func pageService() *service.Service {
+	name := "page-" + strconv.Itoa(int(atomic.AddInt32(&pageSeq, 1)))
+	cache.Register(name, memory.NewMemoryCacher())
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(cache.New(cache.Options{Adapter: name, Conn: "shards=1"}))
+	return ser
+}
+
+func Test_Page(t *testing.T) {
+	ser := pageService()
+	calls := 0
+	ser.Rou.Get("/", cache.Page(time.Minute, nil), func(con *service.Context) {
+		calls++
+		con.Resp.Header().Set("Vary", "Accept-Language")
+		con.Ren.S(200, con.Req.Header.Get("Accept-Language"))
+	})
+	get := func(lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, req)
+		return rec
+	}
+	get("en")
+	rec := get("en")
+	if calls != 1 || rec.Body.String() != "en" || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected cache hit, calls %v body %q", calls, rec.Body.String())
+	}
+	rec = get("zh")
+	if calls != 2 || rec.Body.String() != "zh" {
+		t.Errorf("Vary not respected, calls %v body %q", calls, rec.Body.String())
+	}
+}
+
+func Test_PageVaryConcurrent(t *testing.T) {
+	ser := pageService()
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	ser.Rou.Get("/vary", cache.Page(time.Minute, nil), func(con *service.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+		}
+		con.Resp.Header().Set("Vary", "Accept-Language")
+		con.Resp.Header().Set("X-Request-ID", con.Req.Header.Get("Accept-Language"))
+		con.Ren.S(200, con.Req.Header.Get("Accept-Language"))
+	})
+	get := func(lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/vary", nil)
+		req.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, req)
+		return rec
+	}
+	var wg sync.WaitGroup
+	recs := make(map[string]*httptest.ResponseRecorder)
+	var lock sync.Mutex
+	run := func(name, lang string) {
+		defer wg.Done()
+		rec := get(lang)
+		lock.Lock()
+		recs[name] = rec
+		lock.Unlock()
+	}
+	wg.Add(1)
+	go run("leader", "en")
+	<-started
+	wg.Add(2)
+	go run("en", "en")
+	go run("zh", "zh")
+	// 等待其余请求进入等待
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for name, want := range map[string]string{"leader": "en", "en": "en", "zh": "zh"} {
+		if body := recs[name].Body.String(); body != want {
+			t.Errorf("%s got body %q, want %q", name, body, want)
+		}
+	}
+	if id := recs["en"].Header().Get("X-Request-ID"); id != "" {
+		t.Errorf("per-request header replayed from cache: %q", id)
+	}
+}
+
+// 生成数据的请求 panic 后, 同一 key 的后续请求不会一直等待
+func Test_PagePanic(t *testing.T) {
+	ser := pageService()
+	var calls int32
+	ser.Rou.Get("/panic", cache.Page(time.Minute, nil), func(con *service.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("boom")
+		}
+		con.Ren.S(200, "ok")
+	})
+	func() {
+		defer func() { recover() }()
+		ser.Rou.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+		done <- rec
+	}()
+	select {
+	case rec := <-done:
+		if rec.Body.String() != "ok" {
+			t.Errorf("body after panic = %q", rec.Body.String())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request after panic blocked")
+	}
+}
+
+// 过期后返回旧数据不等待重新生成, 重新生成完成后返回新数据
+func Test_PageStale(t *testing.T) {
+	ser := pageService()
+	var calls int32
+	release := make(chan struct{})
+	ser.Rou.Get("/stale", cache.Page(50*time.Millisecond, nil, cache.PageOptions{Stale: time.Minute}), func(con *service.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 2 {
+			<-release
+		}
+		con.Ren.S(200, strconv.Itoa(int(n)))
+	})
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/stale", nil))
+		return rec
+	}
+	get()
+	time.Sleep(60 * time.Millisecond)
+	if rec := get(); rec.Body.String() != "1" || rec.Header().Get("X-Cache") != "STALE" {
+		t.Fatalf("stale response = %q %q", rec.Body.String(), rec.Header().Get("X-Cache"))
+	}
+	close(release)
+	for i := 0; i < 100; i++ {
+		if rec := get(); rec.Body.String() == "2" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("revalidated entry not served, calls %d", atomic.LoadInt32(&calls))
+}
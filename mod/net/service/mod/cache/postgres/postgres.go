@@ -1,20 +1,44 @@
-package cache
+package postgres
 
 import (
+	"context"
 	"crypto/md5"
 	"database/sql"
-	"github.com/sail-services/sail-go/foundation/framework/ser/cache"
 	"encoding/hex"
-	_ "github.com/lib/pq"
 	"log"
+	"strconv"
 	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
 )
 
+// 数据表 cache_entry 的 expire 为过期的 Unix 时间, 0 为不过期; cache_entry_tag 保存标签与 Key 的对应.
+// 旧版本的 cache 表中 expire 为秒数且数据为 gob 编码, 不再使用, 可自行删除
 type PostgresCacher struct {
 	c        *sql.DB
 	interval int
 }
 
+const (
+	_SQL_TABLE = `CREATE TABLE IF NOT EXISTS cache_entry (
+	key CHAR(32) PRIMARY KEY,
+	data BYTEA,
+	created BIGINT NOT NULL,
+	expire BIGINT NOT NULL
+)`
+	_SQL_TABLE_TAG = `CREATE TABLE IF NOT EXISTS cache_entry_tag (
+	tag TEXT NOT NULL,
+	key CHAR(32) NOT NULL,
+	PRIMARY KEY (tag, key)
+)`
+	_SQL_UPSERT = `INSERT INTO cache_entry(key,data,created,expire) VALUES($1,$2,$3,$4)
+ON CONFLICT (key) DO UPDATE SET data=EXCLUDED.data, created=EXCLUDED.created, expire=EXCLUDED.expire`
+	// 不存在时插入 0, 已过期时重置为 0, 未过期的不变
+	_SQL_INCR_INIT = `INSERT INTO cache_entry(key,data,created,expire) VALUES($1,'0',$2,0)
+ON CONFLICT (key) DO UPDATE SET data='0', created=EXCLUDED.created, expire=0 WHERE cache_entry.expire>0 AND cache_entry.expire<=$2`
+)
+
 func NewPostgresCacher() *PostgresCacher {
 	return &PostgresCacher{}
 }
@@ -24,108 +48,192 @@ func (c *PostgresCacher) md5(key string) string {
 	return hex.EncodeToString(m[:])
 }
 
-func (c *PostgresCacher) Put(key string, val interface{}, expire int64) error {
-	item := &cache.Item{Val: val}
-	data, err := cache.EncodeGob(item)
-	if err != nil {
-		return err
-	}
-	now := time.Now().Unix()
-	if c.Is_Exist(key) {
-		_, err = c.c.Exec("UPDATE cache SET data=$1, created=$2, expire=$3 WHERE key=$4", data, now, expire, c.md5(key))
-	} else {
-		_, err = c.c.Exec("INSERT INTO cache(key,data,created,expire) VALUES($1,$2,$3,$4)", c.md5(key), data, now, expire)
+func (c *PostgresCacher) Get(ctx context.Context, key string) (cache.Value, error) {
+	var data []byte
+	err := c.c.QueryRowContext(ctx, "SELECT data FROM cache_entry WHERE key=$1 AND "+alive(2),
+		c.md5(key), time.Now().Unix()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return cache.Value{}, cache.ErrNotFound
+	} else if err != nil {
+		return cache.Value{}, err
 	}
-	return err
+	return cache.ValueEncoded(data), nil
 }
 
-func (c *PostgresCacher) read(key string) (*cache.Item, error) {
-	var (
-		data    []byte
-		created int64
-		expire  int64
-	)
-	err := c.c.QueryRow("SELECT data,created,expire FROM cache WHERE key=$1", c.md5(key)).Scan(&data, &created, &expire)
+func (c *PostgresCacher) GetMulti(ctx context.Context, keys []string) (map[string]cache.Value, error) {
+	vals := make(map[string]cache.Value, len(keys))
+	if len(keys) == 0 {
+		return vals, nil
+	}
+	hashed := make(map[string]string, len(keys))
+	args := []interface{}{time.Now().Unix()}
+	in := ""
+	for _, key := range keys {
+		h := c.md5(key)
+		hashed[h] = key
+		args = append(args, h)
+		if in != "" {
+			in += ","
+		}
+		in += "$" + strconv.Itoa(len(args))
+	}
+	rows, err := c.c.QueryContext(ctx, "SELECT key,data FROM cache_entry WHERE "+alive(1)+" AND key IN ("+in+")", args...)
 	if err != nil {
 		return nil, err
 	}
-
-	item := new(cache.Item)
-	if err = cache.DecodeGob(data, item); err != nil {
-		return nil, err
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			h    string
+			data []byte
+		)
+		if err = rows.Scan(&h, &data); err != nil {
+			return nil, err
+		}
+		vals[hashed[h]] = cache.ValueEncoded(data)
 	}
-	item.Created = created
-	item.Expire = expire
-	return item, nil
+	return vals, rows.Err()
 }
 
-func (c *PostgresCacher) Get(key string) interface{} {
-	item, err := c.read(key)
-	if err != nil {
-		return nil
-	}
-	if item.Expire > 0 &&
-		(time.Now().Unix()-item.Created) >= item.Expire {
-		c.Delete(key)
+func (c *PostgresCacher) Set(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) error {
+	return c.tx(ctx, func(tx *sql.Tx) error {
+		return c.set(ctx, tx, key, val, ttl, tags)
+	})
+}
+
+func (c *PostgresCacher) SetMulti(ctx context.Context, items map[string]interface{}, ttl time.Duration, tags ...string) error {
+	return c.tx(ctx, func(tx *sql.Tx) error {
+		for key, val := range items {
+			if err := c.set(ctx, tx, key, val, ttl, tags); err != nil {
+				return err
+			}
+		}
 		return nil
-	}
-	return item.Val
+	})
 }
 
-func (c *PostgresCacher) Delete(key string) error {
-	_, err := c.c.Exec("DELETE FROM cache WHERE key=$1", c.md5(key))
-	return err
+func (c *PostgresCacher) Add(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) (added bool, err error) {
+	err = c.tx(ctx, func(tx *sql.Tx) error {
+		// 已过期的行视为不存在, 先删除以便插入
+		now := time.Now().Unix()
+		if _, err := tx.ExecContext(ctx, "DELETE FROM cache_entry WHERE key=$1 AND expire>0 AND expire<=$2", c.md5(key), now); err != nil {
+			return err
+		}
+		data, err := cache.Encode(val)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, "INSERT INTO cache_entry(key,data,created,expire) VALUES($1,$2,$3,$4) ON CONFLICT (key) DO NOTHING",
+			c.md5(key), data, now, expireAt(ttl))
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		added = true
+		return c.tag(ctx, tx, key, tags)
+	})
+	return added && err == nil, err
 }
 
-func (c *PostgresCacher) Incr(key string) error {
-	item, err := c.read(key)
-	if err != nil {
+func (c *PostgresCacher) Delete(ctx context.Context, key string) error {
+	return c.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM cache_entry WHERE key=$1", c.md5(key)); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM cache_entry_tag WHERE key=$1", c.md5(key))
 		return err
-	}
-	item.Val, err = cache.Incr(item.Val)
-	if err != nil {
+	})
+}
+
+// 先确保行存在再加锁读取, 并发的 Incr 在同一行上排队, 新的 Key 也不会丢失增量
+func (c *PostgresCacher) Incr(ctx context.Context, key string, delta int64) (n int64, err error) {
+	err = c.tx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, _SQL_INCR_INIT, c.md5(key), time.Now().Unix())
+		if err != nil {
+			return err
+		}
+		if rows, _ := res.RowsAffected(); rows > 0 {
+			if _, err = tx.ExecContext(ctx, "DELETE FROM cache_entry_tag WHERE key=$1", c.md5(key)); err != nil {
+				return err
+			}
+		}
+		var data []byte
+		if err = tx.QueryRowContext(ctx, "SELECT data FROM cache_entry WHERE key=$1 FOR UPDATE", c.md5(key)).Scan(&data); err != nil {
+			return err
+		}
+		if n, err = cache.ValueEncoded(data).Int64(); err != nil {
+			return cache.ErrNotNumber
+		}
+		n += delta
+		_, err = tx.ExecContext(ctx, "UPDATE cache_entry SET data=$1 WHERE key=$2", strconv.FormatInt(n, 10), c.md5(key))
 		return err
-	}
-	return c.Put(key, item.Val, item.Expire)
+	})
+	return n, err
 }
 
-func (c *PostgresCacher) Decr(key string) error {
-	item, err := c.read(key)
+func (c *PostgresCacher) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	res, err := c.c.ExecContext(ctx, "UPDATE cache_entry SET expire=$3 WHERE key=$1 AND "+alive(2),
+		c.md5(key), time.Now().Unix(), expireAt(ttl))
 	if err != nil {
 		return err
 	}
-	item.Val, err = cache.Decr(item.Val)
-	if err != nil {
-		return err
+	if n, _ := res.RowsAffected(); n == 0 {
+		return cache.ErrNotFound
 	}
-	return c.Put(key, item.Val, item.Expire)
+	return nil
 }
 
-func (c *PostgresCacher) Is_Exist(key string) bool {
-	var data []byte
-	err := c.c.QueryRow("SELECT data FROM cache WHERE key=$1", c.md5(key)).Scan(&data)
-	if err != nil && err != sql.ErrNoRows {
-		panic("cache/postgres: error checking existence: " + err.Error())
+func (c *PostgresCacher) Exist(ctx context.Context, key string) (bool, error) {
+	var one int
+	err := c.c.QueryRowContext(ctx, "SELECT 1 FROM cache_entry WHERE key=$1 AND "+alive(2),
+		c.md5(key), time.Now().Unix()).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-	return err != sql.ErrNoRows
+	return err == nil, err
 }
 
-func (c *PostgresCacher) Flush() error {
-	_, err := c.c.Exec("DELETE FROM cache")
-	return err
+func (c *PostgresCacher) DeleteTag(ctx context.Context, tags ...string) error {
+	return c.tx(ctx, func(tx *sql.Tx) error {
+		for _, tag := range tags {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM cache_entry WHERE key IN (SELECT key FROM cache_entry_tag WHERE tag=$1)", tag); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM cache_entry_tag WHERE key IN (SELECT key FROM cache_entry_tag WHERE tag=$1)", tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *PostgresCacher) Flush(ctx context.Context) error {
+	return c.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM cache_entry"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM cache_entry_tag")
+		return err
+	})
 }
 
 func (c *PostgresCacher) startGC() {
 	if c.interval < 1 {
 		return
 	}
-	if _, err := c.c.Exec("DELETE FROM cache WHERE EXTRACT(EPOCH FROM NOW()) - created >= expire"); err != nil {
+	now := time.Now().Unix()
+	if _, err := c.c.Exec("DELETE FROM cache_entry WHERE expire>0 AND expire<=$1", now); err != nil {
+		log.Printf("cache/postgres: error garbage collecting: %v", err)
+	}
+	if _, err := c.c.Exec("DELETE FROM cache_entry_tag WHERE key NOT IN (SELECT key FROM cache_entry)"); err != nil {
 		log.Printf("cache/postgres: error garbage collecting: %v", err)
 	}
 	time.AfterFunc(time.Duration(c.interval)*time.Second, func() { c.startGC() })
 }
 
-func (c *PostgresCacher) Start_And_GC(opt cache.Options) (err error) {
+func (c *PostgresCacher) StartAndGC(opt cache.Options) (err error) {
 	c.interval = opt.Interval
 	c.c, err = sql.Open("postgres", opt.Conn)
 	if err != nil {
@@ -133,10 +241,64 @@ func (c *PostgresCacher) Start_And_GC(opt cache.Options) (err error) {
 	} else if err = c.c.Ping(); err != nil {
 		return err
 	}
+	for _, stmt := range []string{_SQL_TABLE, _SQL_TABLE_TAG} {
+		if _, err = c.c.Exec(stmt); err != nil {
+			return err
+		}
+	}
 	go c.startGC()
 	return nil
 }
 
+// set 覆盖 Key 的值与标签
+func (c *PostgresCacher) set(ctx context.Context, tx *sql.Tx, key string, val interface{}, ttl time.Duration, tags []string) error {
+	data, err := cache.Encode(val)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, _SQL_UPSERT, c.md5(key), data, time.Now().Unix(), expireAt(ttl)); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM cache_entry_tag WHERE key=$1", c.md5(key)); err != nil {
+		return err
+	}
+	return c.tag(ctx, tx, key, tags)
+}
+
+func (c *PostgresCacher) tag(ctx context.Context, tx *sql.Tx, key string, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO cache_entry_tag(tag,key) VALUES($1,$2) ON CONFLICT DO NOTHING", tag, c.md5(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *PostgresCacher) tx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := c.c.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// alive 返回未过期的条件, arg 为当前时间的参数位置
+func alive(arg int) string {
+	return "(expire=0 OR expire>$" + strconv.Itoa(arg) + ")"
+}
+
+// expireAt 返回过期的 Unix 时间, 不足一秒的部分向上取整
+func expireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl + time.Second - 1).Unix()
+}
+
 func init() {
 	cache.Register("postgres", NewPostgresCacher())
 }
@@ -1,18 +1,25 @@
-package cache
+package redis
 
 import (
+	"context"
 	"fmt"
-	"github.com/sail-services/sail-go/foundation/component/data/convert"
-	"github.com/sail-services/sail-go/foundation/framework/ser/cache"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sail-services/sail-go/com/data/convert"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
+
 	"gopkg.in/ini.v1"
 	"gopkg.in/redis.v2"
 )
 
 var defaultHSetName = "CACHE"
 
+// 标签以 Set 保存, 名称为 prefix + "\x00tag:" + 标签, 成员为带前缀的 Key;
+// 每个 Key 所属的标签 Set 记录在 prefix + "\x00tags:" + Key, 覆盖或删除时从旧标签中移除.
+// 元数据以 \x00 开头, 不会与用户的 Key 冲突. 写入值与更新标签在同一个脚本中完成.
+// 标签 Set 的过期时间不短于其中的 Key, 有不过期的 Key 时不过期
 type RedisCacher struct {
 	c          *redis.Client
 	prefix     string
@@ -20,70 +27,208 @@ type RedisCacher struct {
 	occupyMode bool
 }
 
-func (c *RedisCacher) Put(key string, val interface{}, expire int64) error {
-	key = c.prefix + key
-	if expire == 0 {
-		if err := c.c.Set(key, convert.To_String(val)).Err(); err != nil {
-			return err
-		}
-	} else {
-		dur, err := time.ParseDuration(convert.To_String(expire) + "s")
-		if err != nil {
-			return err
-		}
-		if err = c.c.SetEx(key, dur, convert.To_String(val)).Err(); err != nil {
-			return err
-		}
-	}
-	if c.occupyMode {
-		return nil
+// 脚本的 KEYS[1] 为带前缀的 Key, KEYS[2] 为 Key 的标签记录, KEYS[3] 为记录 Key 的 hset, occupyMode 时为空
+const (
+	_SCRIPT_LIB = `local function extend(name, ttl, created)
+	if ttl == 0 then
+		redis.call('PERSIST', name)
+		return
+	end
+	local cur = redis.call('PTTL', name)
+	if created or (cur >= 0 and cur < ttl) then
+		redis.call('PEXPIRE', name, ttl)
+	end
+end
+local function track(name)
+	if KEYS[3] ~= '' then
+		redis.call('HSET', KEYS[3], name, '0')
+	end
+end
+local function retag(ttl, from)
+	for _, t in ipairs(redis.call('SMEMBERS', KEYS[2])) do
+		redis.call('SREM', t, KEYS[1])
+	end
+	redis.call('DEL', KEYS[2])
+	for i = from, #ARGV do
+		local created = redis.call('EXISTS', ARGV[i]) == 0
+		redis.call('SADD', ARGV[i], KEYS[1])
+		redis.call('SADD', KEYS[2], ARGV[i])
+		extend(ARGV[i], ttl, created)
+		track(ARGV[i])
+	end
+	if #ARGV >= from then
+		if ttl > 0 then
+			redis.call('PEXPIRE', KEYS[2], ttl)
+		end
+		track(KEYS[2])
+	end
+end
+`
+
+	// ARGV[1] 值, ARGV[2] 过期毫秒数, 0 为不过期, ARGV[3] 为 1 时只在不存在时写入, ARGV[4:] 标签 Set
+	_SCRIPT_SET = _SCRIPT_LIB + `local ttl = tonumber(ARGV[2])
+local args = {'SET', KEYS[1], ARGV[1]}
+if ttl > 0 then
+	table.insert(args, 'PX')
+	table.insert(args, ARGV[2])
+end
+if ARGV[3] == '1' then
+	table.insert(args, 'NX')
+end
+if not redis.call(unpack(args)) then
+	return 0
+end
+retag(ttl, 4)
+track(KEYS[1])
+return 1`
+
+	_SCRIPT_DELETE = _SCRIPT_LIB + `redis.call('DEL', KEYS[1])
+retag(0, 1)
+if KEYS[3] ~= '' then
+	redis.call('HDEL', KEYS[3], KEYS[1], KEYS[2])
+end
+return 0`
+
+	// ARGV[1] 增量, 新建的 Key 不过期且没有标签
+	_SCRIPT_INCR = _SCRIPT_LIB + `local existed = redis.call('EXISTS', KEYS[1]) == 1
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if not existed then
+	retag(0, 2)
+	track(KEYS[1])
+end
+return n`
+
+	// ARGV[1] 过期毫秒数, 0 为不过期
+	_SCRIPT_TOUCH = _SCRIPT_LIB + `local ttl = tonumber(ARGV[1])
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+	if redis.call('EXISTS', KEYS[2]) == 1 then
+		redis.call('PEXPIRE', KEYS[2], ttl)
+	end
+else
+	redis.call('PERSIST', KEYS[1])
+	redis.call('PERSIST', KEYS[2])
+end
+for _, t in ipairs(redis.call('SMEMBERS', KEYS[2])) do
+	extend(t, ttl, false)
+end
+return 1`
+)
+
+func (c *RedisCacher) Get(ctx context.Context, key string) (cache.Value, error) {
+	val, err := c.c.Get(c.prefix + key).Result()
+	if err == redis.Nil {
+		return cache.Value{}, cache.ErrNotFound
+	} else if err != nil {
+		return cache.Value{}, err
 	}
-	return c.c.HSet(c.hsetName, key, "0").Err()
+	return cache.ValueEncoded([]byte(val)), nil
 }
 
-func (c *RedisCacher) Get(key string) interface{} {
-	val, err := c.c.Get(c.prefix + key).Result()
+func (c *RedisCacher) GetMulti(ctx context.Context, keys []string) (map[string]cache.Value, error) {
+	vals := make(map[string]cache.Value, len(keys))
+	if len(keys) == 0 {
+		return vals, nil
+	}
+	full := make([]string, len(keys))
+	for i, key := range keys {
+		full[i] = c.prefix + key
+	}
+	res, err := c.c.MGet(full...).Result()
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return val
+	for i, v := range res {
+		if s, ok := v.(string); ok {
+			vals[keys[i]] = cache.ValueEncoded([]byte(s))
+		}
+	}
+	return vals, nil
 }
 
-func (c *RedisCacher) Delete(key string) error {
-	if err := c.c.Del(key).Err(); err != nil {
-		return err
-	}
-	if c.occupyMode {
-		return nil
+func (c *RedisCacher) Set(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) error {
+	_, err := c.set(key, val, ttl, false, tags)
+	return err
+}
+
+func (c *RedisCacher) SetMulti(ctx context.Context, items map[string]interface{}, ttl time.Duration, tags ...string) error {
+	for key, val := range items {
+		if err := c.Set(ctx, key, val, ttl, tags...); err != nil {
+			return err
+		}
 	}
-	return c.c.HDel(c.hsetName, key).Err()
+	return nil
 }
 
-func (c *RedisCacher) Incr(key string) error {
-	if !c.Is_Exist(key) {
-		return fmt.Errorf("key '%s' not exist", key)
+func (c *RedisCacher) Add(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) (bool, error) {
+	return c.set(key, val, ttl, true, tags)
+}
+
+func (c *RedisCacher) Delete(ctx context.Context, key string) error {
+	_, err := c.eval(_SCRIPT_DELETE, key)
+	return err
+}
+
+func (c *RedisCacher) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	n, err := c.eval(_SCRIPT_INCR, key, strconv.FormatInt(delta, 10))
+	if err != nil && strings.Contains(err.Error(), "not an integer") {
+		return 0, cache.ErrNotNumber
 	}
-	return c.c.Incr(c.prefix + key).Err()
+	return n, err
 }
 
-func (c *RedisCacher) Decr(key string) error {
-	if !c.Is_Exist(key) {
-		return fmt.Errorf("key '%s' not exist", key)
+func (c *RedisCacher) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	ok, err := c.eval(_SCRIPT_TOUCH, key, millis(ttl))
+	if err != nil {
+		return err
+	} else if ok == 0 {
+		return cache.ErrNotFound
 	}
-	return c.c.Decr(c.prefix + key).Err()
+	return nil
 }
 
-func (c *RedisCacher) IsExist(key string) bool {
-	if c.c.Exists(c.prefix + key).Val() {
-		return true
+func (c *RedisCacher) Exist(ctx context.Context, key string) (bool, error) {
+	ok, err := c.c.Exists(c.prefix + key).Result()
+	if err != nil {
+		return false, err
 	}
-	if !c.occupyMode {
+	if !ok && !c.occupyMode {
 		c.c.HDel(c.hsetName, c.prefix+key)
 	}
-	return false
+	return ok, nil
 }
 
-func (c *RedisCacher) Flush() error {
+// 标签 Set 中的 Key 可能已过期或被覆盖, 删除不存在的 Key 不影响结果
+func (c *RedisCacher) DeleteTag(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		name := c.tagName(tag)
+		keys, err := c.c.SMembers(name).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			names := make([]string, 0, len(keys)*2)
+			for _, key := range keys {
+				names = append(names, key, c.tagsName(key))
+			}
+			if err = c.c.Del(names...).Err(); err != nil {
+				return err
+			}
+			if !c.occupyMode {
+				c.c.HDel(c.hsetName, names...)
+			}
+		}
+		if err = c.c.Del(name).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RedisCacher) Flush(ctx context.Context) error {
 	if c.occupyMode {
 		return c.c.FlushDb().Err()
 	}
@@ -91,8 +236,10 @@ func (c *RedisCacher) Flush() error {
 	if err != nil {
 		return err
 	}
-	if err = c.c.Del(keys...).Err(); err != nil {
-		return err
+	if len(keys) > 0 {
+		if err = c.c.Del(keys...).Err(); err != nil {
+			return err
+		}
 	}
 	return c.c.Del(c.hsetName).Err()
 }
@@ -100,7 +247,7 @@ func (c *RedisCacher) Flush() error {
 // Conn: "network=tcp,addr=127.0.0.1:6379,password=,db=0,pool_size=100,idle_timeout=180"
 func (c *RedisCacher) StartAndGC(opts cache.Options) error {
 	c.hsetName = "MacaronCache"
-	c.occupyMode = opts.Occupy_Mode
+	c.occupyMode = opts.OccupyMode
 	cfg, err := ini.Load([]byte(strings.Replace(opts.Conn, ",", "\n", -1)))
 	if err != nil {
 		return err
@@ -117,9 +264,9 @@ func (c *RedisCacher) StartAndGC(opts cache.Options) error {
 		case "password":
 			opt.Password = v
 		case "db":
-			opt.DB = convert.String_To(v).Must_Int64()
+			opt.DB = convert.STo(v).MustI64()
 		case "pool_size":
-			opt.PoolSize = convert.String_To(v).Must_Int()
+			opt.PoolSize = convert.STo(v).MustI()
 		case "idle_timeout":
 			opt.IdleTimeout, err = time.ParseDuration(v + "s")
 			if err != nil {
@@ -130,7 +277,7 @@ func (c *RedisCacher) StartAndGC(opts cache.Options) error {
 		case "prefix":
 			c.prefix = v
 		default:
-			return fmt.Errorf("session/redis: unsupported option '%s'", k)
+			return fmt.Errorf("cache/redis: unsupported option '%s'", k)
 		}
 	}
 	c.c = redis.NewClient(opt)
@@ -140,6 +287,57 @@ func (c *RedisCacher) StartAndGC(opts cache.Options) error {
 	return nil
 }
 
+// set 写入值并替换标签, nx 时只在 Key 不存在时写入, 返回是否写入
+func (c *RedisCacher) set(key string, val interface{}, ttl time.Duration, nx bool, tags []string) (bool, error) {
+	data, err := cache.Encode(val)
+	if err != nil {
+		return false, err
+	}
+	mode := "0"
+	if nx {
+		mode = "1"
+	}
+	args := make([]string, 0, len(tags)+3)
+	args = append(args, string(data), millis(ttl), mode)
+	for _, tag := range tags {
+		args = append(args, c.tagName(tag))
+	}
+	n, err := c.eval(_SCRIPT_SET, key, args...)
+	return n == 1, err
+}
+
+// eval 以 Key, 标签记录与 hset 为 KEYS 执行脚本, 返回整数结果
+func (c *RedisCacher) eval(script, key string, args ...string) (int64, error) {
+	key = c.prefix + key
+	hset := ""
+	if !c.occupyMode {
+		hset = c.hsetName
+	}
+	res, err := c.c.Eval(script, []string{key, c.tagsName(key), hset}, args).Result()
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.(int64)
+	return n, nil
+}
+
+func (c *RedisCacher) tagName(tag string) string {
+	return c.prefix + "\x00tag:" + tag
+}
+
+// tagsName 返回记录 Key 所属标签的 Set, key 已带前缀
+func (c *RedisCacher) tagsName(key string) string {
+	return c.prefix + "\x00tags:" + strings.TrimPrefix(key, c.prefix)
+}
+
+// millis 返回过期毫秒数, 不足一毫秒的部分向上取整
+func millis(ttl time.Duration) string {
+	if ttl <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64((ttl+time.Millisecond-1)/time.Millisecond), 10)
+}
+
 func init() {
 	cache.Register("redis", &RedisCacher{})
 }
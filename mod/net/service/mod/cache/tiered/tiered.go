@@ -0,0 +1,281 @@
+package tiered
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache/memory"
+)
+
+type (
+	Options struct {
+		L1        cache.Cache   // 本地缓存, 需已启动 [memory 适配器, 默认配置]
+		L2        cache.Cache   // 远程缓存, 需已启动 [nil]
+		Transport Transport     // 失效广播, 为空时不广播, 仅适用于单实例 [nil]
+		Channel   string        // 广播频道 ["cache:invalidate"]
+		L1TTL     time.Duration // 本地缓存的最长有效期, 限制未收到广播时的过期时间 [1m]
+	}
+	// 读取先查 L1, 未命中时读取 L2 并回填 L1. 写入与删除先操作 L2, 再更新 L1 并广播,
+	// 其他实例收到后删除 L1 中的 Key. L1 不保存标签, 删除标签时各实例清空整个 L1.
+	TieredCacher struct {
+		opt   Options
+		id    string
+		gen   uint64     // 失效的次数, 读取 L2 期间有失效时不回填
+		lock  sync.Mutex // 保护 unsub, 并使回填与失效互斥
+		unsub func()
+	}
+	message struct {
+		From string   `json:"from"`
+		Op   string   `json:"op"`
+		Keys []string `json:"keys,omitempty"`
+	}
+)
+
+const (
+	_OP_DELETE = "delete"
+	_OP_TAG    = "tag"
+	_OP_FLUSH  = "flush"
+)
+
+// 需注册后使用: cache.Register("tiered", tiered.New(tiered.Options{L2: l2, Transport: t}))
+func New(opts ...Options) *TieredCacher {
+	opt := optPrepare(opts)
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &TieredCacher{opt: opt, id: hex.EncodeToString(id)}
+}
+
+func (c *TieredCacher) Get(ctx context.Context, key string) (cache.Value, error) {
+	if val, err := c.opt.L1.Get(ctx, key); err == nil {
+		return val, nil
+	}
+	gen := atomic.LoadUint64(&c.gen)
+	val, err := c.opt.L2.Get(ctx, key)
+	if err != nil {
+		return val, err
+	}
+	c.backfill(ctx, gen, map[string]cache.Value{key: val})
+	return val, nil
+}
+
+func (c *TieredCacher) GetMulti(ctx context.Context, keys []string) (map[string]cache.Value, error) {
+	vals, err := c.opt.L1.GetMulti(ctx, keys)
+	if err != nil {
+		vals = make(map[string]cache.Value, len(keys))
+	}
+	missing := make([]string, 0, len(keys)-len(vals))
+	for _, key := range keys {
+		if _, ok := vals[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return vals, nil
+	}
+	gen := atomic.LoadUint64(&c.gen)
+	remote, err := c.opt.L2.GetMulti(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	c.backfill(ctx, gen, remote)
+	for key, val := range remote {
+		vals[key] = val
+	}
+	return vals, nil
+}
+
+func (c *TieredCacher) Set(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) error {
+	if err := c.opt.L2.Set(ctx, key, val, ttl, tags...); err != nil {
+		return err
+	}
+	c.invalidate(ctx, _OP_DELETE, key)
+	return c.opt.L1.Set(ctx, key, val, c.l1TTL(ttl))
+}
+
+func (c *TieredCacher) SetMulti(ctx context.Context, items map[string]interface{}, ttl time.Duration, tags ...string) error {
+	if err := c.opt.L2.SetMulti(ctx, items, ttl, tags...); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	c.invalidate(ctx, _OP_DELETE, keys...)
+	return c.opt.L1.SetMulti(ctx, items, c.l1TTL(ttl))
+}
+
+func (c *TieredCacher) Add(ctx context.Context, key string, val interface{}, ttl time.Duration, tags ...string) (bool, error) {
+	ok, err := c.opt.L2.Add(ctx, key, val, ttl, tags...)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.invalidate(ctx, _OP_DELETE, key)
+	return true, c.opt.L1.Set(ctx, key, val, c.l1TTL(ttl))
+}
+
+func (c *TieredCacher) Delete(ctx context.Context, key string) error {
+	if err := c.opt.L2.Delete(ctx, key); err != nil {
+		return err
+	}
+	c.invalidate(ctx, _OP_DELETE, key)
+	return nil
+}
+
+// 计数不在 L1 中保存
+func (c *TieredCacher) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	n, err := c.opt.L2.Incr(ctx, key, delta)
+	if err != nil {
+		return n, err
+	}
+	c.invalidate(ctx, _OP_DELETE, key)
+	return n, nil
+}
+
+// 其他实例的 L1 不知道新的过期时间, 与删除一样广播失效
+func (c *TieredCacher) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	if err := c.opt.L2.Touch(ctx, key, ttl); err != nil {
+		return err
+	}
+	c.invalidate(ctx, _OP_DELETE, key)
+	return nil
+}
+
+func (c *TieredCacher) Exist(ctx context.Context, key string) (bool, error) {
+	if ok, err := c.opt.L1.Exist(ctx, key); err == nil && ok {
+		return true, nil
+	}
+	return c.opt.L2.Exist(ctx, key)
+}
+
+func (c *TieredCacher) DeleteTag(ctx context.Context, tags ...string) error {
+	if err := c.opt.L2.DeleteTag(ctx, tags...); err != nil {
+		return err
+	}
+	c.invalidate(ctx, _OP_TAG, tags...)
+	return nil
+}
+
+func (c *TieredCacher) Flush(ctx context.Context) error {
+	if err := c.opt.L2.Flush(ctx); err != nil {
+		return err
+	}
+	c.invalidate(ctx, _OP_FLUSH)
+	return nil
+}
+
+// 订阅失效广播, L1 与 L2 需由调用者启动
+func (c *TieredCacher) StartAndGC(opt cache.Options) error {
+	if c.opt.Transport == nil {
+		return nil
+	}
+	unsub, err := c.opt.Transport.Subscribe(c.opt.Channel, c.receive)
+	if err != nil {
+		return err
+	}
+	// 取消订阅可能等待正在处理的广播, 不能持有锁
+	c.lock.Lock()
+	old := c.unsub
+	c.unsub = unsub
+	c.lock.Unlock()
+	if old != nil {
+		old()
+	}
+	return nil
+}
+
+// backfill 将 L2 读取的值写入 L1, gen 为读取前的失效次数.
+// 检查与写入期间持有锁, 避免失效在两者之间发生而回填旧值
+func (c *TieredCacher) backfill(ctx context.Context, gen uint64, vals map[string]cache.Value) {
+	if len(vals) == 0 {
+		return
+	}
+	items := make(map[string]interface{}, len(vals))
+	for key, val := range vals {
+		items[key] = val.Interface()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if atomic.LoadUint64(&c.gen) != gen {
+		return
+	}
+	c.opt.L1.SetMulti(ctx, items, c.opt.L1TTL)
+}
+
+// invalidate 删除本地 L1 中的 Key 并通知其他实例
+func (c *TieredCacher) invalidate(ctx context.Context, op string, keys ...string) {
+	c.apply(ctx, op, keys)
+	if c.opt.Transport == nil {
+		return
+	}
+	data, err := json.Marshal(message{From: c.id, Op: op, Keys: keys})
+	if err == nil {
+		err = c.opt.Transport.Publish(ctx, c.opt.Channel, data)
+	}
+	if err != nil {
+		log.Printf("cache/tiered: error publishing invalidation: %v", err)
+	}
+}
+
+func (c *TieredCacher) receive(data []byte) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("cache/tiered: error decoding invalidation: %v", err)
+		return
+	}
+	if msg.From == c.id {
+		return
+	}
+	c.apply(context.Background(), msg.Op, msg.Keys)
+}
+
+func (c *TieredCacher) apply(ctx context.Context, op string, keys []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	atomic.AddUint64(&c.gen, 1)
+	switch op {
+	case _OP_DELETE:
+		for _, key := range keys {
+			c.opt.L1.Delete(ctx, key)
+		}
+	case _OP_TAG, _OP_FLUSH:
+		c.opt.L1.Flush(ctx)
+	}
+}
+
+func (c *TieredCacher) l1TTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > c.opt.L1TTL {
+		return c.opt.L1TTL
+	}
+	return ttl
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.L2 == nil {
+		panic("cache/tiered: no L2 cache is given")
+	}
+	if opt.L1 == nil {
+		l1 := memory.NewMemoryCacher()
+		if err := l1.StartAndGC(cache.Options{Adapter: "memory"}); err != nil {
+			panic(err)
+		}
+		opt.L1 = l1
+	}
+	if opt.Channel == "" {
+		opt.Channel = "cache:invalidate"
+	}
+	if opt.L1TTL <= 0 {
+		opt.L1TTL = time.Minute
+	}
+	return opt
+}
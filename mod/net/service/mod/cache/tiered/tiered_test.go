@@ -0,0 +1,163 @@
+package tiered
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache/memory"
+)
+
+// 两个实例共用 L2 与 Transport
+func newPair(t *testing.T) (a, b *TieredCacher, l2 cache.Cache) {
+	l2 = memory.NewMemoryCacher()
+	tr := NewLocalTransport()
+	a = New(Options{L2: l2, Transport: tr})
+	b = New(Options{L2: l2, Transport: tr})
+	for _, c := range []*TieredCacher{a, b} {
+		if err := c.StartAndGC(cache.Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b, l2
+}
+
+func get(t *testing.T, c cache.Cache, key string) string {
+	t.Helper()
+	v, err := c.Get(context.Background(), key)
+	if err == cache.ErrNotFound {
+		return ""
+	} else if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	return v.String()
+}
+
+func Test_Tiered(t *testing.T) {
+	ctx := context.Background()
+	a, b, l2 := newPair(t)
+	a.Set(ctx, "k", "1", 0)
+	if v := get(t, b, "k"); v != "1" {
+		t.Fatalf("b.Get = %q", v)
+	}
+	if v := get(t, b.opt.L1, "k"); v != "1" {
+		t.Errorf("b.L1 not backfilled: %q", v)
+	}
+
+	// L1 命中时不读取 L2
+	l2.Set(ctx, "k", "stale", 0)
+	if v := get(t, b, "k"); v != "1" {
+		t.Errorf("b.Get after L2 change = %q, want L1 value", v)
+	}
+
+	a.Set(ctx, "k", "2", 0)
+	if v := get(t, b.opt.L1, "k"); v != "" {
+		t.Errorf("b.L1 not invalidated: %q", v)
+	}
+	if v := get(t, b, "k"); v != "2" {
+		t.Errorf("b.Get after invalidation = %q", v)
+	}
+
+	a.Delete(ctx, "k")
+	if v := get(t, b, "k"); v != "" {
+		t.Errorf("b.Get after Delete = %q", v)
+	}
+
+	if n, _ := a.Incr(ctx, "n", 2); n != 2 {
+		t.Errorf("Incr = %d", n)
+	}
+	if ok, _ := b.Exist(ctx, "n"); !ok {
+		t.Errorf("counter not in L2")
+	}
+}
+
+func Test_TieredMulti(t *testing.T) {
+	ctx := context.Background()
+	a, b, _ := newPair(t)
+	a.SetMulti(ctx, map[string]interface{}{"x": 1, "y": 2}, time.Minute)
+	b.Get(ctx, "x")
+	vals, err := b.GetMulti(ctx, []string{"x", "y", "z"})
+	if err != nil || len(vals) != 2 {
+		t.Fatalf("GetMulti = %v, %v", vals, err)
+	}
+	if n, _ := vals["y"].Int64(); n != 2 {
+		t.Errorf("y = %v", n)
+	}
+	if ok, _ := b.Add(ctx, "x", 3, 0); ok {
+		t.Errorf("Add of existing key succeeded")
+	}
+}
+
+func Test_TieredTag(t *testing.T) {
+	ctx := context.Background()
+	a, b, _ := newPair(t)
+	a.Set(ctx, "p", "1", 0, "user:1")
+	a.Set(ctx, "q", "2", 0)
+	get(t, b, "p")
+	get(t, b, "q")
+	a.DeleteTag(ctx, "user:1")
+	if v := get(t, b, "p"); v != "" {
+		t.Errorf("tagged key after DeleteTag = %q", v)
+	}
+	if v := get(t, b, "q"); v != "2" {
+		t.Errorf("untagged key after DeleteTag = %q", v)
+	}
+	b.Flush(ctx)
+	if v := get(t, a, "q"); v != "" {
+		t.Errorf("a.Get after b.Flush = %q", v)
+	}
+}
+
+func Test_TieredTouch(t *testing.T) {
+	ctx := context.Background()
+	a, b, _ := newPair(t)
+	a.Set(ctx, "k", "1", time.Hour)
+	get(t, b, "k")
+	if err := a.Touch(ctx, "k", time.Minute); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if v := get(t, b.opt.L1, "k"); v != "" {
+		t.Errorf("b.L1 kept after Touch: %q", v)
+	}
+	if v := get(t, b, "k"); v != "1" {
+		t.Errorf("b.Get after Touch = %q", v)
+	}
+}
+
+// 并发读取的回填不能覆盖之后写入的值
+func Test_TieredBackfill(t *testing.T) {
+	ctx := context.Background()
+	a, b, _ := newPair(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			a.Set(ctx, "k", i, 0)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			b.Get(ctx, "k")
+		}
+	}
+	if v := get(t, b, "k"); v != "499" {
+		t.Errorf("b.Get after writes = %q", v)
+	}
+}
+
+func Test_LocalTransport(t *testing.T) {
+	tr := NewLocalTransport()
+	var got []string
+	unsub, _ := tr.Subscribe("c", func(msg []byte) { got = append(got, string(msg)) })
+	tr.Subscribe("other", func(msg []byte) { t.Errorf("message on other channel") })
+	tr.Publish(context.Background(), "c", []byte("1"))
+	unsub()
+	tr.Publish(context.Background(), "c", []byte("2"))
+	if len(got) != 1 || got[0] != "1" {
+		t.Errorf("received %v", got)
+	}
+}
@@ -0,0 +1,56 @@
+package tiered
+
+import (
+	"context"
+	"sync"
+)
+
+// 实例间广播失效消息, 实现需将消息送达订阅同一频道的所有实例, 包括发送者自己
+type Transport interface {
+	Publish(ctx context.Context, channel string, msg []byte) error
+	// 订阅频道, 返回取消订阅的函数
+	Subscribe(channel string, fn func(msg []byte)) (func(), error)
+}
+
+// 进程内的 Transport, 用于测试或单进程内的多个实例, Publish 返回前已同步调用所有订阅者
+type LocalTransport struct {
+	lock sync.RWMutex
+	seq  int
+	subs map[string]map[int]func([]byte)
+}
+
+func NewLocalTransport() *LocalTransport {
+	return &LocalTransport{subs: make(map[string]map[int]func([]byte))}
+}
+
+func (t *LocalTransport) Publish(ctx context.Context, channel string, msg []byte) error {
+	t.lock.RLock()
+	fns := make([]func([]byte), 0, len(t.subs[channel]))
+	for _, fn := range t.subs[channel] {
+		fns = append(fns, fn)
+	}
+	t.lock.RUnlock()
+	for _, fn := range fns {
+		fn(msg)
+	}
+	return nil
+}
+
+func (t *LocalTransport) Subscribe(channel string, fn func([]byte)) (func(), error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.seq++
+	id := t.seq
+	if t.subs[channel] == nil {
+		t.subs[channel] = make(map[int]func([]byte))
+	}
+	t.subs[channel][id] = fn
+	return func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		delete(t.subs[channel], id)
+		if len(t.subs[channel]) == 0 {
+			delete(t.subs, channel)
+		}
+	}, nil
+}
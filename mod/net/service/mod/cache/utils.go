@@ -1,76 +1,157 @@
 package cache
 
 import (
-	"bytes"
-	"encoding/gob"
-	"errors"
+	"encoding/json"
+	"reflect"
+	"strconv"
 )
 
-type Item struct {
-	Val     interface{}
-	Created int64
-	Expire  int64
+// 读取的值. 内存适配器保存原值, 其他适配器保存编码后的字节, 读取时按需要的类型转换
+type Value struct {
+	val     interface{}
+	data    []byte
+	encoded bool
 }
 
-func EncodeGob(item *Item) ([]byte, error) {
-	buf := bytes.NewBuffer(nil)
-	err := gob.NewEncoder(buf).Encode(item)
-	return buf.Bytes(), err
+// 由原值创建, 用于不编码的适配器
+func ValueOf(val interface{}) Value {
+	return Value{val: val}
 }
 
-func DecodeGob(data []byte, out *Item) error {
-	buf := bytes.NewBuffer(data)
-	return gob.NewDecoder(buf).Decode(&out)
+// 由 Encode 编码的字节创建
+func ValueEncoded(data []byte) Value {
+	return Value{data: data, encoded: true}
 }
 
-func Incr(val interface{}) (interface{}, error) {
-	switch val.(type) {
-	case int:
-		val = val.(int) + 1
-	case int32:
-		val = val.(int32) + 1
-	case int64:
-		val = val.(int64) + 1
-	case uint:
-		val = val.(uint) + 1
-	case uint32:
-		val = val.(uint32) + 1
-	case uint64:
-		val = val.(uint64) + 1
-	default:
-		return val, errors.New("item value is not int-type")
+// 编码为字节: 字符串与 []byte 原样保存, 数字与布尔值保存为文本以支持 Incr, 其他类型为 JSON
+func Encode(val interface{}) ([]byte, error) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case bool:
+		return []byte(strconv.FormatBool(v)), nil
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []byte(strconv.FormatInt(rv.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []byte(strconv.FormatUint(rv.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		return []byte(strconv.FormatFloat(rv.Float(), 'g', -1, 64)), nil
 	}
-	return val, nil
+	return json.Marshal(val)
 }
 
-func Decr(val interface{}) (interface{}, error) {
-	switch val.(type) {
-	case int:
-		val = val.(int) - 1
-	case int32:
-		val = val.(int32) - 1
-	case int64:
-		val = val.(int64) - 1
-	case uint:
-		if val.(uint) > 0 {
-			val = val.(uint) - 1
-		} else {
-			return val, errors.New("item value is less than 0")
+// 按 out 指向的类型解码 Encode 编码的字节
+func Decode(data []byte, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(out)}
+	}
+	elem := rv.Elem()
+	switch elem.Kind() {
+	case reflect.String:
+		elem.SetString(string(data))
+	case reflect.Slice:
+		if elem.Type().Elem().Kind() != reflect.Uint8 {
+			return json.Unmarshal(data, out)
+		}
+		elem.SetBytes(append([]byte(nil), data...))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(string(data))
+		if err != nil {
+			return err
+		}
+		elem.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(string(data), 10, elem.Type().Bits())
+		if err != nil {
+			return ErrNotNumber
+		}
+		elem.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(string(data), 10, elem.Type().Bits())
+		if err != nil {
+			return ErrNotNumber
 		}
-	case uint32:
-		if val.(uint32) > 0 {
-			val = val.(uint32) - 1
-		} else {
-			return val, errors.New("item value is less than 0")
+		elem.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(string(data), elem.Type().Bits())
+		if err != nil {
+			return ErrNotNumber
 		}
-	case uint64:
-		if val.(uint64) > 0 {
-			val = val.(uint64) - 1
-		} else {
-			return val, errors.New("item value is less than 0")
+		elem.SetFloat(f)
+	case reflect.Interface:
+		if elem.NumMethod() > 0 {
+			return json.Unmarshal(data, out)
 		}
+		elem.Set(reflect.ValueOf(string(data)))
 	default:
-		return val, errors.New("item value is not int-type")
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// ========================================================
+// Value
+// ========================================================
+// 原值, 编码保存的值返回字符串
+func (v Value) Interface() interface{} {
+	if v.encoded {
+		return string(v.data)
+	}
+	return v.val
+}
+
+func (v Value) String() string {
+	var s string
+	v.Decode(&s)
+	return s
+}
+
+func (v Value) Bytes() []byte {
+	var b []byte
+	v.Decode(&b)
+	return b
+}
+
+func (v Value) Int64() (int64, error) {
+	var i int64
+	err := v.Decode(&i)
+	return i, err
+}
+
+func (v Value) Float64() (float64, error) {
+	var f float64
+	err := v.Decode(&f)
+	return f, err
+}
+
+func (v Value) Bool() (bool, error) {
+	var b bool
+	err := v.Decode(&b)
+	return b, err
+}
+
+// 解码到 out 指向的值, 原值类型可赋值时直接赋值
+func (v Value) Decode(out interface{}) error {
+	if !v.encoded {
+		rv := reflect.ValueOf(out)
+		if rv.Kind() == reflect.Ptr && !rv.IsNil() && v.val != nil {
+			src := reflect.ValueOf(v.val)
+			if src.Type().AssignableTo(rv.Elem().Type()) {
+				rv.Elem().Set(src)
+				return nil
+			}
+		}
+		data, err := Encode(v.val)
+		if err != nil {
+			return err
+		}
+		return Decode(data, out)
 	}
-	return val, nil
+	return Decode(v.data, out)
 }
@@ -0,0 +1,176 @@
+package cors
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	Options struct {
+		Origins       []string                 // 允许的来源, 支持 * 与 https://*.example.com [*]
+		OriginRegexps []string                 // 允许来源的正则, 需匹配整个来源 [nil]
+		OriginFunc    func(origin string) bool // 自定义来源判断 [nil]
+		Methods       []string                 // 允许的方法 [GET, POST, PUT, PATCH, DELETE, HEAD]
+		Headers       []string                 // 允许的请求 Header, 为空时按预检请求返回 [nil]
+		ExposeHeaders []string                 // 暴露给前端的 Header [nil]
+		Credentials   bool                     // 允许携带 Cookie, 不能与来源 * 同时使用 [false]
+		MaxAge        int                      // 预检结果缓存秒数 [0]
+	}
+	wildcard struct {
+		prefix string
+		suffix string
+	}
+	cors struct {
+		opt       Options
+		all       bool
+		origins   map[string]bool
+		wildcards []wildcard
+		regexps   []*regexp.Regexp
+		methods   map[string]bool
+	}
+)
+
+const (
+	_DATA_CORS = "_DATA_CORS"
+)
+
+// 处理跨域请求与预检 OPTIONS 请求, 预检请求无需注册路由
+func New(opts ...Options) service.Handler {
+	c := corsNew(optPrepare(opts))
+	return func(con *service.Context) {
+		origin := con.Req.Header.Get("Origin")
+		if origin == "" {
+			return
+		}
+		hd := con.Resp.Header()
+		hd.Add("Vary", "Origin")
+		preflight := con.Req.Method == "OPTIONS" && con.Req.Header.Get("Access-Control-Request-Method") != ""
+		matched := c.originMatch(origin)
+		if !matched && !c.all {
+			if preflight {
+				con.Abort(http.StatusForbidden)
+			}
+			return
+		}
+		if matched {
+			con.DataSet(_DATA_CORS, origin)
+		}
+		c.originSet(hd, origin)
+		if !preflight {
+			if len(c.opt.ExposeHeaders) > 0 {
+				hd.Set("Access-Control-Expose-Headers", strings.Join(c.opt.ExposeHeaders, ", "))
+			}
+			return
+		}
+		hd.Add("Vary", "Access-Control-Request-Method")
+		hd.Add("Vary", "Access-Control-Request-Headers")
+		method := strings.ToUpper(con.Req.Header.Get("Access-Control-Request-Method"))
+		if !c.methods[method] {
+			con.Abort(http.StatusForbidden)
+			return
+		}
+		hd.Set("Access-Control-Allow-Methods", strings.Join(c.opt.Methods, ", "))
+		if len(c.opt.Headers) > 0 {
+			hd.Set("Access-Control-Allow-Headers", strings.Join(c.opt.Headers, ", "))
+		} else if req := con.Req.Header.Get("Access-Control-Request-Headers"); req != "" {
+			hd.Set("Access-Control-Allow-Headers", req)
+		}
+		if c.opt.MaxAge > 0 {
+			hd.Set("Access-Control-Max-Age", strconv.Itoa(c.opt.MaxAge))
+		}
+		con.Abort(http.StatusNoContent)
+	}
+}
+
+// 请求来源是否被 CORS 模块明确允许, 仅由 * 允许的来源不算
+func IsAllowed(con *service.Context) bool {
+	_, ok := con.DataGet(_DATA_CORS)
+	return ok
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if len(opt.Origins) == 0 && len(opt.OriginRegexps) == 0 && opt.OriginFunc == nil {
+		opt.Origins = []string{"*"}
+	}
+	if opt.Credentials {
+		for _, o := range opt.Origins {
+			if o == "*" {
+				panic("cors: origin * can't be used with Credentials")
+			}
+		}
+	}
+	if len(opt.Methods) == 0 {
+		opt.Methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	}
+	for i, m := range opt.Methods {
+		opt.Methods[i] = strings.ToUpper(m)
+	}
+	return opt
+}
+
+// ========================================================
+// cors
+// ========================================================
+func corsNew(opt Options) *cors {
+	c := &cors{
+		opt:     opt,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+	}
+	for _, o := range opt.Origins {
+		o = strings.ToLower(o)
+		if o == "*" {
+			c.all = true
+		} else if i := strings.Index(o, "*"); i > -1 {
+			c.wildcards = append(c.wildcards, wildcard{o[:i], o[i+1:]})
+		} else {
+			c.origins[o] = true
+		}
+	}
+	for _, r := range opt.OriginRegexps {
+		c.regexps = append(c.regexps, regexp.MustCompile("^(?:"+r+")$"))
+	}
+	for _, m := range opt.Methods {
+		c.methods[m] = true
+	}
+	c.methods["OPTIONS"] = true
+	return c
+}
+
+// originMatch 判断来源是否匹配 * 以外的配置
+func (c *cors) originMatch(origin string) bool {
+	o := strings.ToLower(origin)
+	if c.origins[o] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if len(o) > len(w.prefix)+len(w.suffix) && strings.HasPrefix(o, w.prefix) && strings.HasSuffix(o, w.suffix) {
+			return true
+		}
+	}
+	for _, r := range c.regexps {
+		if r.MatchString(origin) {
+			return true
+		}
+	}
+	return c.opt.OriginFunc != nil && c.opt.OriginFunc(origin)
+}
+
+func (c *cors) originSet(hd http.Header, origin string) {
+	if c.all {
+		hd.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		hd.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.opt.Credentials {
+		hd.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
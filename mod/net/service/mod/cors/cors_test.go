@@ -0,0 +1,105 @@
+package cors_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cors"
+)
+
+func Test_CORS(t *testing.T) {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(cors.New(cors.Options{
+		Origins:     []string{"https://app.example.com", "https://*.example.org"},
+		Credentials: true,
+		MaxAge:      600,
+	}))
+	ser.Rou.Get("/api", func(con *service.Context) {
+		con.Ren.S(200, "ok")
+	})
+	do := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api", nil)
+		req.Header.Set("Origin", origin)
+		if method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := do("OPTIONS", "https://a.example.org")
+	if rec.Code != 204 || rec.Header().Get("Access-Control-Allow-Origin") != "https://a.example.org" ||
+		rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight: %v %v", rec.Code, rec.Header())
+	}
+	rec = do("GET", "https://app.example.com")
+	if rec.Code != 200 || rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("simple request: %v %v", rec.Code, rec.Header())
+	}
+	rec = do("OPTIONS", "https://evil.com")
+	if rec.Code != 403 || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin: %v %v", rec.Code, rec.Header())
+	}
+}
+
+func Test_IsAllowedWildcard(t *testing.T) {
+	for _, tc := range []struct {
+		origins []string
+		want    bool
+	}{
+		{nil, false},
+		{[]string{"*", "https://app.example.com"}, true},
+	} {
+		ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+		ser.Module(cors.New(cors.Options{Origins: tc.origins}))
+		var allowed bool
+		ser.Rou.Post("/api", func(con *service.Context) {
+			allowed = cors.IsAllowed(con)
+			con.Ren.S(200, "ok")
+		})
+		req := httptest.NewRequest("POST", "/api", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, req)
+		if rec.Header().Get("Access-Control-Allow-Origin") == "" || allowed != tc.want {
+			t.Errorf("origins %v: IsAllowed = %v, header %v", tc.origins, allowed, rec.Header())
+		}
+	}
+}
+
+func Test_OriginRegexps(t *testing.T) {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(cors.New(cors.Options{OriginRegexps: []string{`https://app\.example\.com`}, Credentials: true}))
+	ser.Rou.Get("/api", func(con *service.Context) {
+		con.Ren.S(200, "ok")
+	})
+	for origin, want := range map[string]bool{
+		"https://app.example.com":          true,
+		"https://app.example.com.evil.com": false,
+		"evil-https://app.example.com":     false,
+	} {
+		req := httptest.NewRequest("GET", "/api", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin") == origin; got != want {
+			t.Errorf("%s allowed = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func Test_CredentialsWildcard(t *testing.T) {
+	for _, origins := range [][]string{nil, {"https://app.example.com", "*"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Origins %v with Credentials accepted", origins)
+				}
+			}()
+			cors.New(cors.Options{Origins: origins, Credentials: true})
+		}()
+	}
+}
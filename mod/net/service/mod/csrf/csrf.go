@@ -3,123 +3,139 @@ package csrf
 import (
 	"bytes"
 	"crypto/hmac"
-	"crypto/sha1"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sail-services/sail-go/com/data/convert"
 	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cors"
 	"github.com/sail-services/sail-go/mod/net/service/mod/session"
 )
 
 type (
 	Options struct {
-		SecretKey      string // 密钥 [nil]
-		Header         string // Header 中的 Token 名 [X-CSRF]
-		Form           string // Post 中的 Token 名 [CSRF]
-		Cookie         string // Cookie 中的 Token 名 [CSRF]
-		CookiePath     string // Cookie 的路径 [/]
-		Session        string // 要处理的 Session 名 [nil]
-		Origin         bool
-		RespHaveHeader bool                        // 返回 Header 是否有密钥 [false]
-		RespHaveCookie bool                        // 返回 Cookie 是否有密钥 [false]
-		ErrorFunc      func(w http.ResponseWriter) // 设置出错 func
+		SecretKey      string                                // 密钥 [service.SecretKeyGet()]
+		Header         string                                // Header 中的 Token 名 [X-CSRF]
+		Form           string                                // Post 中的 Token 名 [CSRF]
+		Cookie         string                                // Cookie 中的 Token 名 [CSRF]
+		CookiePath     string                                // Cookie 的路径 [/]
+		CookieSecure   bool                                  // Cookie 只在 HTTPS 中发送 [false]
+		SameSite       http.SameSite                         // Cookie 的 SameSite [Lax]
+		Session        string                                // Session 中用户 ID 的键名, 为空时绑定 Session ID [nil]
+		DoubleSubmit   bool                                  // 双重提交 Cookie 模式, 不需要 session 模块 [false]
+		SaltCookie     string                                // 双重提交模式中保存随机值的 Cookie 名 [CSRF_SALT]
+		Check          bool                                  // 对所有非安全方法的请求验证 Token [false]
+		Exempt         []string                              // 不验证的路径, 以 * 结尾时匹配前缀 [nil]
+		ExemptFunc     func(con *service.Context) bool       // 自定义不验证的请求 [nil]
+		Origin         bool                                  // cors 模块明确允许 (非 *) 的跨域请求跳过 Token 处理 [false]
+		RespHaveHeader bool                                  // 返回 Header 是否有密钥 [false]
+		RespHaveCookie bool                                  // 返回 Cookie 是否有密钥 [false]
+		Timeout        time.Duration                         // Token 有效期 [24h]
+		ErrorFunc      func(con *service.Context, err error) // 验证失败的处理 [状态码与错误文本]
 	}
 	CSRF interface {
-		HeaderGet() string        // 获取 Header 中的 Token 名
-		FormGet() string          // 获取 Form 中的 Token 名
-		CookieGet() string        // 获取 Cookie 中的 Token 名
-		CookiePathGet() string    // 获取 Cookie 路径
-		TokenGet() string         // 获取 Token
-		TokenValid(t string) bool // 验证 Token
-		IDGet() string            // 获取 ID
-		Error(w http.ResponseWriter)
+		HeaderGet() string                     // 获取 Header 中的 Token 名
+		FormGet() string                       // 获取 Form 中的 Token 名
+		CookieGet() string                     // 获取 Cookie 中的 Token 名
+		CookiePathGet() string                 // 获取 Cookie 路径
+		TokenGet() string                      // 获取 Token
+		TokenValid(t string) bool              // 验证 Token
+		IDGet() string                         // 获取 ID
+		Error(con *service.Context, err error) // 返回验证失败
 	}
 	csrf struct {
-		Header     string
-		Form       string
-		Token      string
-		ID         string
-		Cookie     string
-		CookiePath string
-		SecretKey  string
-		ErrorFunc  func(w http.ResponseWriter)
+		opt   *Options
+		Token string
+		ID    string
+		salt  string
 	}
 )
 
 const (
-	_DATA_CSRF = "_DATA_CSRF"
-	_TIMEOUT   = 24 * time.Hour
+	_DATA_CSRF    = "_DATA_CSRF"
+	_SESSION_SALT = "_CSRF_SALT"
+	_SALT_LENGTH  = 16
 )
 
+var (
+	ErrTokenMissing = errors.New("csrf: token missing")
+	ErrTokenInvalid = errors.New("csrf: token invalid or expired")
+	ErrNoSession    = errors.New("csrf: session module is required unless DoubleSubmit is set")
+)
+
+// 没有加载 session 模块且不是双重提交模式时, 首次请求记录错误, 所有请求返回 500
 func New(opts ...Options) service.Handler {
-	var id_have bool
 	opt := optPrepare(opts)
+	var once sync.Once
 	return func(con *service.Context) {
-		x := &csrf{
-			SecretKey:  opt.SecretKey,
-			Header:     opt.Header,
-			Form:       opt.Form,
-			Cookie:     opt.Cookie,
-			CookiePath: opt.CookiePath,
-			ErrorFunc:  opt.ErrorFunc}
-		con.DataSet(_DATA_CSRF, x)
-		sess := session.DataGetStore(con)
-		id := sess.Get(opt.Session)
-		if id == nil {
-			id, id_have = con.Req.SecureCookieGet(opt.Session)
-			if !id_have {
-				id = "0"
-				con.Resp.CookieSet(x.CookieGet(), "", -1, x.CookiePathGet())
-			} else {
-				sess.Set(opt.Session, id)
+		x := &csrf{opt: &opt}
+		if opt.DoubleSubmit {
+			x.salt = con.Req.CookieGet(opt.SaltCookie)
+			if !saltValid(x.salt) {
+				x.salt = saltNew()
+				con.Resp.CookieSet(opt.SaltCookie, x.salt, 0, opt.CookiePath, "", opt.CookieSecure, true, opt.SameSite)
 			}
+		} else {
+			if !session.DataHasStore(con) {
+				once.Do(func() {
+					con.Log.Errorln(ErrNoSession)
+				})
+				con.AbortWithError(http.StatusInternalServerError, ErrNoSession)
+				con.Ren.S(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			sess := session.DataGetStore(con)
+			x.ID = sess.ID()
+			if opt.Session != "" {
+				if id := sess.Get(opt.Session); id != nil {
+					x.ID = convert.ToS(id)
+				}
+			}
+			salt, _ := sess.Get(_SESSION_SALT).(string)
+			if !saltValid(salt) {
+				salt = saltNew()
+				sess.Set(_SESSION_SALT, salt)
+			}
+			x.salt = salt
 		}
-		switch id.(type) {
-		case string:
-			x.ID = id.(string)
-		case int:
-		case int64:
-			x.ID = convert.ToS(id)
-		default:
-			return
-		}
-		if opt.Origin && len(con.Req.Header.Get("Origin")) > 0 {
-			return
-		}
-		if val := con.Req.CookieGet(opt.Cookie); len(val) != 0 {
+		con.DataSet(_DATA_CSRF, x)
+		if val := con.Req.CookieGet(opt.Cookie); val != "" && x.TokenValid(val) {
 			x.Token = val
 		} else {
-			tm := time.Now()
-			x.Token = generateTokenAtTime(x.SecretKey, x.ID, "POST", tm)
-			if opt.RespHaveCookie && x.ID != "0" {
-				con.Resp.CookieSet(opt.Cookie, x.Token, 0, opt.CookiePath)
+			x.Token = x.tokenAt(time.Now())
+			if opt.RespHaveCookie {
+				con.Resp.CookieSet(opt.Cookie, x.Token, 0, opt.CookiePath, "", opt.CookieSecure, false, opt.SameSite)
 			}
 		}
 		if opt.RespHaveHeader {
-			con.Resp.Header().Add(opt.Header, x.Token)
+			con.Resp.Header().Set(opt.Header, x.Token)
+		}
+		if opt.Check {
+			Validate(con)
 		}
 	}
 }
 
+// 验证 Header 或表单中的 Token, 安全方法与排除的路径不验证, 失败时停止后续处理
 func Validate(con *service.Context) {
-	x := DataCSRFGet(con)
-	token := con.Req.Header.Get(x.HeaderGet())
-	if token == "" {
-		token = con.Req.FormValue(x.FormGet())
-	} else {
-		if !x.TokenValid(token) {
-			con.Resp.CookieSet(x.CookieGet(), "", -1, x.CookiePathGet())
-			x.Error(con.Resp)
-		}
-		return
+	v, ok := con.DataGet(_DATA_CSRF)
+	if !ok {
+		panic("csrf: Validate used without csrf module")
+	}
+	x := v.(*csrf)
+	if err := x.check(con); err != nil {
+		x.Error(con, err)
 	}
-	http.Error(con.Resp, "Bad Request: no CSRF token represnet", http.StatusBadRequest)
 }
 
 func ValidateWithSAndID(con *service.Context, token, id string) bool {
@@ -133,6 +149,24 @@ func ValidateWithSAndID(con *service.Context, token, id string) bool {
 	return true
 }
 
+// 使当前会话已发放的 Token 失效, 登录等权限变化后调用
+func Rotate(con *service.Context) {
+	x := DataCSRFGet(con).(*csrf)
+	x.salt = saltNew()
+	if x.opt.DoubleSubmit {
+		con.Resp.CookieSet(x.opt.SaltCookie, x.salt, 0, x.opt.CookiePath, "", x.opt.CookieSecure, true, x.opt.SameSite)
+	} else {
+		session.DataGetStore(con).Set(_SESSION_SALT, x.salt)
+	}
+	x.Token = x.tokenAt(time.Now())
+	if x.opt.RespHaveCookie {
+		con.Resp.CookieSet(x.opt.Cookie, x.Token, 0, x.opt.CookiePath, "", x.opt.CookieSecure, false, x.opt.SameSite)
+	}
+	if x.opt.RespHaveHeader {
+		con.Resp.Header().Set(x.opt.Header, x.Token)
+	}
+}
+
 func DataCSRFGet(con *service.Context) CSRF {
 	return con.DataMustGet(_DATA_CSRF).(CSRF)
 }
@@ -143,7 +177,10 @@ func optPrepare(options []Options) Options {
 		opt = options[0]
 	}
 	if opt.SecretKey == "" {
-		panic("Must have SecretKey")
+		opt.SecretKey = service.SecretKeyGet()
+	}
+	if opt.SecretKey == "" {
+		panic("csrf: SecretKey is not set")
 	}
 	if opt.Header == "" {
 		opt.Header = "X-CSRF"
@@ -157,32 +194,50 @@ func optPrepare(options []Options) Options {
 	if opt.CookiePath == "" {
 		opt.CookiePath = "/"
 	}
-	if opt.Session == "" {
-		panic("Must have Session")
+	if opt.SameSite == 0 {
+		opt.SameSite = http.SameSiteLaxMode
+	}
+	if opt.SaltCookie == "" {
+		opt.SaltCookie = "CSRF_SALT"
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 24 * time.Hour
+	}
+	if opt.ErrorFunc == nil {
+		opt.ErrorFunc = func(con *service.Context, err error) {
+			con.Ren.S(con.Resp.Status(), err.Error())
+		}
 	}
 	return opt
 }
 
-func generateTokenAtTime(secret_key, user_id, action_id string, now time.Time) string {
-	h := hmac.New(sha1.New, []byte(secret_key))
-	fmt.Fprintf(h, "%s:%s:%d", strings.Replace(user_id, ":", "_", -1), strings.Replace(action_id, ":", "_", -1), now.UnixNano())
-	token := fmt.Sprintf("%s:%d", h.Sum(nil), now.UnixNano())
-	return base64.URLEncoding.EncodeToString([]byte(token))
+func saltNew() string {
+	b := make([]byte, _SALT_LENGTH)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func saltValid(salt string) bool {
+	if len(salt) != _SALT_LENGTH*2 {
+		return false
+	}
+	_, err := hex.DecodeString(salt)
+	return err == nil
 }
 
 // ========================================================
 // csrf
 // ========================================================
 func (c *csrf) HeaderGet() string {
-	return c.Header
+	return c.opt.Header
 }
 
 func (c *csrf) FormGet() string {
-	return c.Form
+	return c.opt.Form
 }
 
 func (c *csrf) CookieGet() string {
-	return c.Cookie
+	return c.opt.Cookie
 }
 
 func (c *csrf) IDGet() string {
@@ -190,7 +245,7 @@ func (c *csrf) IDGet() string {
 }
 
 func (c *csrf) CookiePathGet() string {
-	return c.CookiePath
+	return c.opt.CookiePath
 }
 
 func (c *csrf) TokenGet() string {
@@ -212,16 +267,67 @@ func (c *csrf) TokenValid(token string) bool {
 		return false
 	}
 	issueTime := time.Unix(0, nanos)
-	if now.Sub(issueTime) >= _TIMEOUT {
+	if now.Sub(issueTime) >= c.opt.Timeout {
 		return false
 	}
 	if issueTime.After(now.Add(1 * time.Minute)) {
 		return false
 	}
-	expected := generateTokenAtTime(c.SecretKey, c.ID, "POST", issueTime)
+	expected := c.tokenAt(issueTime)
 	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
 }
 
-func (c *csrf) Error(w http.ResponseWriter) {
-	c.ErrorFunc(w)
+// 缺少 Token 返回 400, Token 错误返回 403
+func (c *csrf) Error(con *service.Context, err error) {
+	status := http.StatusForbidden
+	if err == ErrTokenMissing {
+		status = http.StatusBadRequest
+	}
+	con.Abort(status)
+	c.opt.ErrorFunc(con, err)
+}
+
+func (c *csrf) tokenAt(now time.Time) string {
+	h := hmac.New(sha256.New, []byte(c.opt.SecretKey))
+	fmt.Fprintf(h, "%s:%s:%d", strings.Replace(c.ID, ":", "_", -1), c.salt, now.UnixNano())
+	token := fmt.Sprintf("%s:%d", h.Sum(nil), now.UnixNano())
+	return base64.URLEncoding.EncodeToString([]byte(token))
+}
+
+func (c *csrf) check(con *service.Context) error {
+	switch con.Req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return nil
+	}
+	if c.exempt(con) {
+		return nil
+	}
+	if c.opt.Origin && cors.IsAllowed(con) {
+		return nil
+	}
+	token := con.Req.Header.Get(c.opt.Header)
+	if token == "" {
+		token = con.Req.FormValue(c.opt.Form)
+	}
+	if token == "" {
+		return ErrTokenMissing
+	}
+	if !c.TokenValid(token) {
+		return ErrTokenInvalid
+	}
+	return nil
+}
+
+func (c *csrf) exempt(con *service.Context) bool {
+	p := con.Req.URL.Path
+	for _, e := range c.opt.Exempt {
+		if strings.HasSuffix(e, "*") {
+			if strings.HasPrefix(p, e[:len(e)-1]) {
+				return true
+			}
+		} else if p == e {
+			return true
+		}
+	}
+	return c.opt.ExemptFunc != nil && c.opt.ExemptFunc(con)
 }
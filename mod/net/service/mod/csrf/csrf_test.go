@@ -0,0 +1,97 @@
+package csrf_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/csrf"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	_ "github.com/sail-services/sail-go/mod/net/service/mod/session/memory"
+)
+
+func serviceNew(opt csrf.Options, sess bool) *service.Service {
+	service.SecretKeySet("0123456789abcdef0123456789abcdef")
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	if sess {
+		ser.Module(session.New(session.Options{Gclifetime: 3600}))
+	}
+	ser.Module(csrf.New(opt))
+	ser.Rou.Get("/token", func(con *service.Context) {
+		con.Ren.S(200, csrf.DataCSRFGet(con).TokenGet())
+	})
+	ser.Rou.Post("/submit", csrf.Validate, func(con *service.Context) {
+		con.Ren.S(200, "ok")
+	})
+	ser.Rou.Post("/hook/in", func(con *service.Context) {
+		con.Ren.S(200, "hook")
+	})
+	return ser
+}
+
+func do(ser *service.Service, req *http.Request, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	return rec
+}
+
+func form(token string) *http.Request {
+	req := httptest.NewRequest("POST", "/submit", strings.NewReader(url.Values{"CSRF": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func Test_DoubleSubmit(t *testing.T) {
+	ser := serviceNew(csrf.Options{DoubleSubmit: true}, false)
+	rec := do(ser, httptest.NewRequest("GET", "/token", nil), nil)
+	cookies := (&http.Response{Header: rec.Header()}).Cookies()
+	token := rec.Body.String()
+	if rec = do(ser, form(token), cookies); rec.Code != 200 {
+		t.Errorf("form token rejected: %d %s", rec.Code, rec.Body.String())
+	}
+	req := httptest.NewRequest("POST", "/submit", nil)
+	req.Header.Set("X-CSRF", token)
+	if rec = do(ser, req, cookies); rec.Code != 200 {
+		t.Errorf("header token rejected: %d", rec.Code)
+	}
+	if rec = do(ser, form(token), nil); rec.Code != 403 || rec.Body.String() == "ok" {
+		t.Errorf("token accepted without salt cookie: %d", rec.Code)
+	}
+	if rec = do(ser, httptest.NewRequest("POST", "/submit", nil), cookies); rec.Code != 400 {
+		t.Errorf("missing token: %d", rec.Code)
+	}
+}
+
+func Test_SessionCheckAndExempt(t *testing.T) {
+	ser := serviceNew(csrf.Options{Check: true, Exempt: []string{"/hook/*"}}, true)
+	rec := do(ser, httptest.NewRequest("GET", "/token", nil), nil)
+	cookies := (&http.Response{Header: rec.Header()}).Cookies()
+	if rec = do(ser, form(rec.Body.String()), cookies); rec.Code != 200 {
+		t.Errorf("session token rejected: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec = do(ser, form("bad"), cookies); rec.Code != 403 {
+		t.Errorf("bad token: %d", rec.Code)
+	}
+	if rec = do(ser, httptest.NewRequest("POST", "/hook/in", nil), nil); rec.Code != 200 {
+		t.Errorf("exempt path: %d", rec.Code)
+	}
+}
+
+// 缺少 session 模块时记录一次错误并返回 500, 不在请求中 panic
+func Test_NoSession(t *testing.T) {
+	ser := serviceNew(csrf.Options{}, false)
+	for i := 0; i < 2; i++ {
+		rec := do(ser, httptest.NewRequest("GET", "/token", nil), nil)
+		if rec.Code != 500 {
+			t.Errorf("status without session = %d", rec.Code)
+		}
+	}
+}
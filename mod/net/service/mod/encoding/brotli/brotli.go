@@ -0,0 +1,17 @@
+package brotli
+
+import (
+	"github.com/sail-services/sail-go/mod/net/service/mod/encoding"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 导入即为 encoding 模块注册 br 编码
+func init() {
+	encoding.Register(encoding.ENCODING_BROTLI, func(level int) encoding.Encoder {
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(nil, level)
+	})
+}
@@ -0,0 +1,311 @@
+package encoding
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	Encoder interface {
+		io.WriteCloser
+		Flush() error
+		Reset(w io.Writer)
+	}
+	Options struct {
+		Encodings []string       // 服务端优先顺序, 只使用已注册的编码 [br, gzip, deflate]
+		Levels    map[string]int // 各编码的压缩级别, 如 {"gzip": 6, "br": 4}, 未设置为各编码的默认值 [nil]
+		MinLength int            // 小于该长度不压缩 [1024]
+		Types     []string       // 允许压缩的 Content-Type, 以 / 结尾时匹配前缀 [text/, 常见 JSON/JS/XML/SVG/字体]
+	}
+	encoding struct {
+		opt   Options
+		pools map[string]*sync.Pool
+		types map[string]bool
+	}
+	encResponse struct {
+		service.Response
+		e        *encoding
+		name     string
+		buf      []byte
+		decided  bool
+		hijacked bool
+		enc      Encoder
+	}
+)
+
+const (
+	ENCODING_BROTLI  = "br"
+	ENCODING_GZIP    = "gzip"
+	ENCODING_DEFLATE = "deflate"
+
+	_ACCEPT_ENCODING  = "Accept-Encoding"
+	_CONTENT_ENCODING = "Content-Encoding"
+	_CONTENT_LENGTH   = "Content-Length"
+	_CONTENT_TYPE     = "Content-Type"
+)
+
+var (
+	encoders = make(map[string]func(level int) Encoder)
+)
+
+func init() {
+	Register(ENCODING_GZIP, func(level int) Encoder {
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		w, err := gzip.NewWriterLevel(nil, level)
+		if err != nil {
+			panic("encoding: " + err.Error())
+		}
+		return w
+	})
+	Register(ENCODING_DEFLATE, func(level int) Encoder {
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		w, err := flate.NewWriter(nil, level)
+		if err != nil {
+			panic("encoding: " + err.Error())
+		}
+		return w
+	})
+}
+
+// 注册编码, 如 br 由 encoding/brotli 包注册
+func Register(name string, newFunc func(level int) Encoder) {
+	if newFunc == nil {
+		panic("encoding: Register encoder is nil")
+	}
+	if _, dup := encoders[name]; dup {
+		panic("encoding: Register called twice for encoder " + name)
+	}
+	encoders[name] = newFunc
+}
+
+// 按 Accept-Encoding 协商压缩, 长度不足或类型不符时原样输出
+func New(opts ...Options) service.Handler {
+	e := encodingNew(optPrepare(opts))
+	return func(con *service.Context) {
+		if con.Req.Method == "HEAD" || strings.Contains(con.Req.Header.Get("Connection"), "Upgrade") {
+			con.Next()
+			return
+		}
+		name := e.negotiate(con.Req.Header.Get(_ACCEPT_ENCODING))
+		if name == "" {
+			con.Next()
+			return
+		}
+		er := &encResponse{Response: con.Resp, e: e, name: name}
+		con.Resp = er
+		con.Next()
+		con.Resp = er.Response
+		er.finish()
+	}
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if len(opt.Encodings) == 0 {
+		opt.Encodings = []string{ENCODING_BROTLI, ENCODING_GZIP, ENCODING_DEFLATE}
+	}
+	if opt.MinLength == 0 {
+		opt.MinLength = 1024
+	}
+	if len(opt.Types) == 0 {
+		opt.Types = []string{
+			"text/",
+			"application/json",
+			"application/javascript",
+			"application/xml",
+			"application/xhtml+xml",
+			"application/rss+xml",
+			"application/atom+xml",
+			"application/wasm",
+			"image/svg+xml",
+			"font/ttf",
+			"font/otf",
+		}
+	}
+	return opt
+}
+
+// ========================================================
+// encoding
+// ========================================================
+func encodingNew(opt Options) *encoding {
+	e := &encoding{opt: opt, pools: make(map[string]*sync.Pool), types: make(map[string]bool)}
+	for _, name := range opt.Encodings {
+		newFunc, ok := encoders[name]
+		if !ok {
+			continue
+		}
+		level := opt.Levels[name]
+		pool := &sync.Pool{New: func() interface{} {
+			return newFunc(level)
+		}}
+		// 启动时创建一次, 级别无效时在此 panic 而不是在请求中
+		pool.Put(newFunc(level))
+		e.pools[name] = pool
+	}
+	for _, t := range opt.Types {
+		e.types[strings.ToLower(t)] = true
+	}
+	return e
+}
+
+// 选择 q 值最高的编码, q 值相同时按 Options.Encodings 的顺序
+func (e *encoding) negotiate(accept string) string {
+	if accept == "" {
+		return ""
+	}
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i > -1 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	best, best_q := "", 0.0
+	for _, name := range e.opt.Encodings {
+		if e.pools[name] == nil {
+			continue
+		}
+		q, ok := qs[name]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > best_q {
+			best, best_q = name, q
+		}
+	}
+	return best
+}
+
+func (e *encoding) typeAllowed(content_type string) bool {
+	t, _, err := mime.ParseMediaType(content_type)
+	if err != nil {
+		return false
+	}
+	if e.types[t] {
+		return true
+	}
+	if i := strings.IndexByte(t, '/'); i > -1 {
+		return e.types[t[:i+1]]
+	}
+	return false
+}
+
+// ========================================================
+// encResponse
+// ========================================================
+// 决定是否压缩, force 为 Flush 时不再等待达到 MinLength
+func (er *encResponse) decide(force bool) {
+	if er.decided {
+		return
+	}
+	er.decided = true
+	hd := er.Header()
+	if er.compressible(force) {
+		hd.Set(_CONTENT_ENCODING, er.name)
+		hd.Del(_CONTENT_LENGTH)
+		er.enc = er.e.pools[er.name].Get().(Encoder)
+		er.enc.Reset(er.Response)
+		if len(er.buf) > 0 {
+			er.enc.Write(er.buf)
+		}
+	} else if len(er.buf) > 0 {
+		er.Response.Write(er.buf)
+	}
+	er.buf = nil
+}
+
+func (er *encResponse) compressible(force bool) bool {
+	hd := er.Header()
+	switch status := er.Status(); {
+	case status < 200, status == http.StatusNoContent, status == http.StatusNotModified, status == http.StatusPartialContent:
+		return false
+	}
+	if hd.Get(_CONTENT_ENCODING) != "" {
+		return false
+	}
+	content_type := hd.Get(_CONTENT_TYPE)
+	if content_type == "" {
+		if len(er.buf) == 0 {
+			return false
+		}
+		content_type = http.DetectContentType(er.buf)
+		hd.Set(_CONTENT_TYPE, content_type)
+	}
+	if !er.e.typeAllowed(content_type) {
+		return false
+	}
+	hd.Add("Vary", _ACCEPT_ENCODING)
+	return force || len(er.buf) >= er.e.opt.MinLength
+}
+
+func (er *encResponse) finish() {
+	if er.hijacked {
+		return
+	}
+	er.decide(false)
+	if er.enc != nil {
+		er.enc.Close()
+		er.enc.Reset(nil)
+		er.e.pools[er.name].Put(er.enc)
+		er.enc = nil
+	}
+}
+
+// --------------------------------------------------------
+// encResponse - GO
+// --------------------------------------------------------
+func (er *encResponse) Write(p []byte) (int, error) {
+	if !er.decided {
+		if n, err := strconv.Atoi(er.Header().Get(_CONTENT_LENGTH)); err == nil && n < er.e.opt.MinLength {
+			er.decide(false)
+		} else {
+			er.buf = append(er.buf, p...)
+			if len(er.buf) >= er.e.opt.MinLength {
+				er.decide(false)
+			}
+			return len(p), nil
+		}
+	}
+	if er.enc != nil {
+		return er.enc.Write(p)
+	}
+	return er.Response.Write(p)
+}
+
+func (er *encResponse) Flush() {
+	er.decide(true)
+	if er.enc != nil {
+		er.enc.Flush()
+	}
+	er.Response.Flush()
+}
+
+func (er *encResponse) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	er.hijacked = true
+	return er.Response.Hijack()
+}
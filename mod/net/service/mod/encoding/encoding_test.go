@@ -0,0 +1,108 @@
+package encoding_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/encoding"
+)
+
+var (
+	large = strings.Repeat("hello world ", 200)
+)
+
+func serve(url, accept string) *httptest.ResponseRecorder {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(encoding.New())
+	ser.Rou.Get("/large", func(con *service.Context) {
+		con.Ren.S(200, large)
+	})
+	ser.Rou.Get("/small", func(con *service.Context) {
+		con.Ren.S(200, "hello")
+	})
+	ser.Rou.Get("/image", func(con *service.Context) {
+		con.Resp.Header().Set("Content-Type", "image/png")
+		con.Ren.B(200, []byte(large))
+	})
+	ser.Rou.Get("/stream", func(con *service.Context) {
+		con.Resp.Header().Set("Content-Type", "text/event-stream")
+		con.Resp.Write([]byte("data: 1\n\n"))
+		con.Resp.Flush()
+		con.Resp.Write([]byte("data: 2\n\n"))
+	})
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set("Accept-Encoding", accept)
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	return rec
+}
+
+func gunzip(t *testing.T, rec *httptest.ResponseRecorder) string {
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("not gzip encoded: %v", rec.Header())
+	}
+	r, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(r)
+	return string(data)
+}
+
+func Test_Negotiate(t *testing.T) {
+	if body := gunzip(t, serve("/large", "deflate;q=0.5, gzip")); body != large {
+		t.Errorf("body mismatch")
+	}
+	if rec := serve("/large", "deflate"); rec.Header().Get("Content-Encoding") != "deflate" {
+		t.Errorf("deflate not used: %v", rec.Header())
+	}
+	if rec := serve("/large", "gzip;q=0, identity"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != large {
+		t.Errorf("q=0 not honoured: %v", rec.Header())
+	}
+}
+
+func Test_Skip(t *testing.T) {
+	if rec := serve("/small", "gzip"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "hello" {
+		t.Errorf("small body compressed: %v", rec.Header())
+	}
+	if rec := serve("/image", "gzip"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != large {
+		t.Errorf("image compressed: %v", rec.Header())
+	}
+}
+
+func Test_Flush(t *testing.T) {
+	rec := serve("/stream", "gzip")
+	if !rec.Flushed {
+		t.Errorf("flush not forwarded")
+	}
+	if body := gunzip(t, rec); body != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("stream body %q", body)
+	}
+}
+
+func Test_Levels(t *testing.T) {
+	levels := make(map[string]int)
+	for _, name := range []string{"x-a", "x-b"} {
+		name := name
+		encoding.Register(name, func(level int) encoding.Encoder {
+			levels[name] = level
+			w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+			return w
+		})
+	}
+	encoding.New(encoding.Options{Encodings: []string{"x-a", "x-b"}, Levels: map[string]int{"x-a": 9}})
+	if levels["x-a"] != 9 || levels["x-b"] != 0 {
+		t.Errorf("levels = %v", levels)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("invalid gzip level accepted")
+		}
+	}()
+	encoding.New(encoding.Options{Levels: map[string]int{encoding.ENCODING_GZIP: 42}})
+}
@@ -0,0 +1,123 @@
+package etag
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	Options struct {
+		Weak    bool                                           // 生成弱 ETag [false]
+		MaxSize int                                            // 超过该长度不再缓冲计算 [4MB]
+		Current func(con *service.Context) (string, time.Time) // PUT/PATCH/DELETE 时获取资源当前 ETag 与修改时间 [nil]
+	}
+	etagResponse struct {
+		service.Response
+		buf   *bytes.Buffer
+		max   int
+		flush bool
+	}
+)
+
+func New(opts ...Options) service.Handler {
+	opt := optPrepare(opts)
+	return func(con *service.Context) {
+		switch con.Req.Method {
+		case "GET", "HEAD":
+		case "PUT", "PATCH", "DELETE":
+			if opt.Current != nil {
+				tag, modified := opt.Current(con)
+				if status := service.ConditionCheck(con.Req.Request, tag, modified); status != 0 {
+					con.Abort(status)
+				}
+			}
+			return
+		default:
+			return
+		}
+		er := &etagResponse{Response: con.Resp, buf: new(bytes.Buffer), max: opt.MaxSize}
+		con.Resp = er
+		con.Next()
+		con.Resp = er.Response
+		if er.flush {
+			return
+		}
+		status := er.Status()
+		if status == http.StatusNotModified || status == http.StatusPreconditionFailed {
+			return
+		}
+		hd := er.Header()
+		if status == http.StatusOK && hd.Get("ETag") == "" {
+			hd.Set("ETag", generate(er.buf.Bytes(), opt.Weak))
+			modified, _ := http.ParseTime(hd.Get("Last-Modified"))
+			if status = service.ConditionCheck(con.Req.Request, hd.Get("ETag"), modified); status != 0 {
+				hd.Del("Content-Type")
+				hd.Del("Content-Length")
+				er.WriteHeader(status)
+				return
+			}
+		}
+		if er.buf.Len() > 0 {
+			er.Response.Write(er.buf.Bytes())
+		}
+	}
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.MaxSize == 0 {
+		opt.MaxSize = 4 << 20
+	}
+	return opt
+}
+
+func generate(data []byte, weak bool) string {
+	h := fnv.New64a()
+	h.Write(data)
+	tag := fmt.Sprintf(`"%x-%x"`, len(data), h.Sum64())
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// ========================================================
+// etagResponse
+// ========================================================
+// 超出 MaxSize 或调用 Flush 后转为直接输出
+func (er *etagResponse) passThrough() {
+	if er.flush {
+		return
+	}
+	er.flush = true
+	if er.buf.Len() > 0 {
+		er.Response.Write(er.buf.Bytes())
+		er.buf.Reset()
+	}
+}
+
+// --------------------------------------------------------
+// etagResponse - GO
+// --------------------------------------------------------
+func (er *etagResponse) Write(p []byte) (int, error) {
+	if !er.flush && er.buf.Len()+len(p) > er.max {
+		er.passThrough()
+	}
+	if er.flush {
+		return er.Response.Write(p)
+	}
+	return er.buf.Write(p)
+}
+
+func (er *etagResponse) Flush() {
+	er.passThrough()
+	er.Response.Flush()
+}
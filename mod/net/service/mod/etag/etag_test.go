@@ -0,0 +1,61 @@
+package etag_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/etag"
+)
+
+func serve(ser *service.Service, method string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_ETag(t *testing.T) {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(etag.New())
+	ser.Rou.Get("/", func(con *service.Context) {
+		con.Ren.S(200, "hello")
+	})
+	rec := serve(ser, "GET")
+	tag := rec.Header().Get("ETag")
+	if rec.Code != 200 || rec.Body.String() != "hello" || tag == "" {
+		t.Fatalf("first request: %v %q etag %q", rec.Code, rec.Body.String(), tag)
+	}
+	rec = serve(ser, "GET", "If-None-Match", tag)
+	if rec.Code != 304 || rec.Body.Len() != 0 {
+		t.Errorf("If-None-Match: %v %q", rec.Code, rec.Body.String())
+	}
+	rec = serve(ser, "GET", "If-None-Match", `"other"`)
+	if rec.Code != 200 || rec.Body.String() != "hello" {
+		t.Errorf("If-None-Match mismatch: %v %q", rec.Code, rec.Body.String())
+	}
+}
+
+func Test_ETagHandler(t *testing.T) {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(etag.New())
+	called := 0
+	ser.Rou.Put("/", func(con *service.Context) {
+		if con.Resp.ETag("v2") {
+			return
+		}
+		called++
+		con.Ren.S(200, "saved")
+	})
+	if rec := serve(ser, "PUT", "If-Match", `"v1"`); rec.Code != 412 || called != 0 {
+		t.Errorf("If-Match mismatch: %v, called %v", rec.Code, called)
+	}
+	if rec := serve(ser, "PUT", "If-Match", `"v2"`); rec.Code != 200 || called != 1 {
+		t.Errorf("If-Match match: %v, called %v", rec.Code, called)
+	}
+}
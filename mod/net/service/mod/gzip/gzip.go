@@ -24,6 +24,7 @@ const (
 	_ENCODING_GZIP    = "gzip"
 )
 
+// Deprecated: 使用 encoding 模块, 支持协商、最小长度与类型过滤
 func New(level int) service.Handler {
 	return func(con *service.Context) {
 		if strings.Contains(con.Req.Header.Get("Connection"), "Upgrade") || !strings.Contains(con.Req.Header.Get(_ACCEPT_ENCODING), _ENCODING_GZIP) {
@@ -2,6 +2,7 @@ package i18n
 
 import (
 	"fmt"
+	"io/fs"
 	"net/http"
 	"path"
 	"strings"
@@ -15,6 +16,7 @@ type (
 		Langs       []string // 语言 [nil]
 		Names       []string // 语言名 [nil]
 		Dir         string   // 目录 [lang]
+		FS          fs.FS    // 语言文件系统, 如 embed.FS, 设置后忽略 Dir [nil]
 		Tmpl        string   // 模版中的变量名 [lang]
 		Url         bool     // 使用URL设定语言 [false]
 		UrlVar      string   // URL设定语言 [lang] (/?lang=zh-CN)
@@ -133,7 +135,15 @@ func optPrepare(options []Options) Options {
 func initLocales(opt Options) {
 	for i, lang := range opt.Langs {
 		fname := fmt.Sprintf(opt.format, lang)
-		err := i18n.SetMessageWithDesc(lang, opt.Names[i], path.Join(opt.Dir, fname))
+		var source interface{} = path.Join(opt.Dir, fname)
+		if opt.FS != nil {
+			data, err := fs.ReadFile(opt.FS, fname)
+			if err != nil {
+				panic(fmt.Errorf("fail to read message file(%s): %v", lang, err))
+			}
+			source = data
+		}
+		err := i18n.SetMessageWithDesc(lang, opt.Names[i], source)
 		if err != nil && err != i18n.ErrLangAlreadyExist {
 			panic(fmt.Errorf("fail to set message file(%s): %v", lang, err))
 		} else if err != nil {
@@ -1,22 +1,195 @@
 package log
 
 import (
-	"github.com/sail-services/sail-go/mod/net/service"
+	"encoding/json"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
 	"time"
+
+	rr_log "github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/requestid"
 )
 
-func New() service.Handler {
+type (
+	Options struct {
+		Format    string                          // 格式 default / combined / json / 含 {字段} 的模版 [default]
+		Log       *rr_log.Log                     // 访问日志输出, 为空时使用 con.Log [nil]
+		Sample    float64                         // 记录 2xx/3xx 请求的比例, 0 为全部记录 [0]
+		SkipPaths []string                        // 不记录的路径前缀, 如 /health [nil]
+		SkipExts  []string                        // 不记录的扩展名, 如 .js .css [nil]
+		Skip      func(con *service.Context) bool // 自定义跳过规则 [nil]
+		Slow      time.Duration                   // 超过时长的请求以 WARN 记录, json 中标记 slow [0]
+	}
+	entry struct {
+		Time     string  `json:"time"`
+		IP       string  `json:"ip"`
+		Method   string  `json:"method"`
+		URI      string  `json:"uri"`
+		Proto    string  `json:"proto"`
+		Status   int     `json:"status"`
+		Size     int     `json:"size"`
+		Duration float64 `json:"duration_ms"`
+		Referer  string  `json:"referer,omitempty"`
+		Agent    string  `json:"user_agent,omitempty"`
+		ID       string  `json:"request_id,omitempty"`
+		Slow     bool    `json:"slow,omitempty"`
+	}
+)
+
+const (
+	FORMAT_DEFAULT  = "default"
+	FORMAT_COMBINED = "combined"
+	FORMAT_JSON     = "json"
+
+	_TIME_COMBINED = "02/Jan/2006:15:04:05 -0700"
+)
+
+// 模版中可用的字段: {time} {ip} {method} {uri} {path} {proto} {status} {size} {duration} {referer} {ua} {id}
+func New(opts ...Options) service.Handler {
+	opt := optPrepare(opts)
 	return func(con *service.Context) {
+		if skip(con, opt) {
+			con.Next()
+			return
+		}
 		start := time.Now()
 		con.Next()
-		if con.Opt.Log {
-			con.Log.Infof(
-				"%v %v %v (%v)\n",
-				con.Req.Method,
-				con.Resp.Status(),
-				con.Req.RequestURI,
-				time.Now().Sub(start),
-			)
+		if !con.Opt.Log {
+			return
+		}
+		status := con.Resp.Status()
+		if opt.Sample > 0 && status < 400 && rand.Float64() >= opt.Sample {
+			return
+		}
+		e := entryNew(con, start)
+		slow := opt.Slow > 0 && time.Since(start) >= opt.Slow
+		out := opt.Log
+		if out == nil {
+			out = con.Log
+		}
+		switch opt.Format {
+		case FORMAT_DEFAULT:
+			logf(out, slow, "%v %v %v (%v)\n", e.Method, e.Status, e.URI, time.Since(start))
+		case FORMAT_COMBINED:
+			out.Write([]byte(combined(e, start)))
+		case FORMAT_JSON:
+			e.Slow = slow
+			data, _ := json.Marshal(e)
+			out.Write(append(data, '\n'))
+		default:
+			logf(out, slow, "%s\n", template(opt.Format, e, con))
+		}
+	}
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Format == "" {
+		opt.Format = FORMAT_DEFAULT
+	}
+	if opt.Format != FORMAT_DEFAULT && opt.Format != FORMAT_COMBINED && opt.Format != FORMAT_JSON && !strings.Contains(opt.Format, "{") {
+		panic("log: unknown format " + opt.Format)
+	}
+	if opt.Sample < 0 || opt.Sample > 1 {
+		panic("log: sample must be between 0 and 1")
+	}
+	// 复制后再处理, 不修改调用方的切片
+	exts := make([]string, len(opt.SkipExts))
+	for i, ext := range opt.SkipExts {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
 		}
+		exts[i] = ext
+	}
+	opt.SkipExts = exts
+	return opt
+}
+
+func skip(con *service.Context, opt Options) bool {
+	p := con.Req.URL.Path
+	for _, prefix := range opt.SkipPaths {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	if len(opt.SkipExts) > 0 {
+		ext := strings.ToLower(path.Ext(p))
+		for _, e := range opt.SkipExts {
+			if ext == e {
+				return true
+			}
+		}
+	}
+	return opt.Skip != nil && opt.Skip(con)
+}
+
+func entryNew(con *service.Context, start time.Time) *entry {
+	size := con.Resp.Size()
+	if size < 0 {
+		size = 0
+	}
+	return &entry{
+		Time:     start.Format(time.RFC3339),
+		IP:       con.Req.IP(),
+		Method:   con.Req.Method,
+		URI:      con.Req.RequestURI,
+		Proto:    con.Req.Proto,
+		Status:   con.Resp.Status(),
+		Size:     size,
+		Duration: float64(time.Since(start).Nanoseconds()) / 1e6,
+		Referer:  con.Req.Referer(),
+		Agent:    con.Req.UserAgent(),
+		ID:       requestid.DataGetID(con),
+	}
+}
+
+func logf(out *rr_log.Log, slow bool, format string, v ...interface{}) {
+	if slow {
+		out.Warnf(format, v...)
+	} else {
+		out.Infof(format, v...)
+	}
+}
+
+// Apache combined: ip - - [time] "method uri proto" status size "referer" "ua"
+func combined(e *entry, start time.Time) string {
+	size := "-"
+	if e.Size > 0 {
+		size = strconv.Itoa(e.Size)
+	}
+	return e.IP + ` - - [` + start.Format(_TIME_COMBINED) + `] ` +
+		strconv.Quote(e.Method+" "+e.URI+" "+e.Proto) + " " +
+		strconv.Itoa(e.Status) + " " + size + " " +
+		strconv.Quote(dash(e.Referer)) + " " + strconv.Quote(dash(e.Agent)) + "\n"
+}
+
+func template(format string, e *entry, con *service.Context) string {
+	return strings.NewReplacer(
+		"{time}", e.Time,
+		"{ip}", e.IP,
+		"{method}", e.Method,
+		"{uri}", e.URI,
+		"{path}", con.Req.URL.Path,
+		"{proto}", e.Proto,
+		"{status}", strconv.Itoa(e.Status),
+		"{size}", strconv.Itoa(e.Size),
+		"{duration}", strconv.FormatFloat(e.Duration, 'f', 3, 64)+"ms",
+		"{referer}", dash(e.Referer),
+		"{ua}", dash(e.Agent),
+		"{id}", dash(e.ID),
+	).Replace(format)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
 	}
+	return s
 }
@@ -0,0 +1,72 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rr_log "github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/log"
+)
+
+func serve(opt log.Options, url string) string {
+	buf := new(bytes.Buffer)
+	opt.Log = rr_log.New(buf, rr_log.LEVEL_INFO, rr_log.DATA_BASIC)
+	ser := service.New(rr_log.New(ioutil.Discard, rr_log.LEVEL_INFO, rr_log.DATA_NONE))
+	ser.Module(log.New(opt))
+	ser.Rou.Get("/hello", func(con *service.Context) {
+		con.Ren.S(200, "hello")
+	})
+	ser.Rou.Get("/slow", func(con *service.Context) {
+		time.Sleep(5 * time.Millisecond)
+		con.Ren.S(200, "slow")
+	})
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set("User-Agent", "test-agent")
+	ser.Rou.ServeHTTP(httptest.NewRecorder(), req)
+	return buf.String()
+}
+
+func Test_Formats(t *testing.T) {
+	if out := serve(log.Options{}, "/hello"); !strings.HasPrefix(out, "[INFO] GET 200 /hello (") {
+		t.Errorf("default: %q", out)
+	}
+	out := serve(log.Options{Format: log.FORMAT_COMBINED}, "/hello")
+	if !strings.Contains(out, `"GET /hello HTTP/1.1" 200 5 "-" "test-agent"`) {
+		t.Errorf("combined: %q", out)
+	}
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(serve(log.Options{Format: log.FORMAT_JSON}, "/hello")), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e["status"] != float64(200) || e["uri"] != "/hello" || e["size"] != float64(5) {
+		t.Errorf("json: %v", e)
+	}
+	if out := serve(log.Options{Format: "{method} {path} {status} {ua}"}, "/hello"); out != "[INFO] GET /hello 200 test-agent\n" {
+		t.Errorf("template: %q", out)
+	}
+}
+
+func Test_SkipAndSlow(t *testing.T) {
+	if out := serve(log.Options{SkipPaths: []string{"/hel"}}, "/hello"); out != "" {
+		t.Errorf("skip path: %q", out)
+	}
+	exts := []string{"css", "JS"}
+	if out := serve(log.Options{SkipExts: exts}, "/a.css"); out != "" {
+		t.Errorf("skip ext: %q", out)
+	}
+	if out := serve(log.Options{SkipExts: exts}, "/a.js"); out != "" {
+		t.Errorf("skip upper-case ext: %q", out)
+	}
+	if exts[0] != "css" || exts[1] != "JS" {
+		t.Errorf("SkipExts modified: %v", exts)
+	}
+	if out := serve(log.Options{Slow: time.Millisecond}, "/slow"); !strings.HasPrefix(out, "[WARN]") {
+		t.Errorf("slow: %q", out)
+	}
+}
@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+)
+
+type (
+	Options struct {
+		Path        string    // 输出路径 [/metrics]
+		Namespace   string    // 指标名前缀 [http]
+		Buckets     []float64 // 耗时分布 (秒) [0.005 ... 10]
+		SizeBuckets []float64 // 返回大小分布 (字节) [100 ... 10000000]
+	}
+	histogram struct {
+		buckets []float64
+		counts  []uint64
+		sum     float64
+		count   uint64
+	}
+	series struct {
+		labels   string
+		requests uint64
+		duration *histogram
+		size     *histogram
+	}
+	metrics struct {
+		lock     sync.Mutex
+		opt      Options
+		series   map[string]*series
+		inflight int64
+		sessions func() int
+	}
+)
+
+const (
+	_ROUTE_NOT_FOUND = "NotFound"
+	_METHOD_OTHER    = "OTHER"
+)
+
+var (
+	// 标准方法以外的方法记为 OTHER, 避免任意方法名产生大量序列
+	methods = map[string]bool{
+		"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+		"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+	}
+)
+
+// 按 方法/路由模式/状态码 记录请求, 并以 Prometheus 文本格式输出
+func New(opts ...Options) service.Handler {
+	m := &metrics{opt: optPrepare(opts), series: make(map[string]*series)}
+	return func(con *service.Context) {
+		if con.Req.URL.Path == m.opt.Path && con.Req.Method == "GET" {
+			if session.DataHasStore(con) {
+				m.sessionsSet(session.DataGetStore(con).Count)
+			}
+			con.Opt.Log = false
+			con.Resp.Header().Set("Content-Type", "text/plain; version=0.0.4"+service.CharsetGetHeader())
+			con.Resp.WriteHeader(200)
+			con.Resp.Write(m.expose())
+			con.Abort(0)
+			return
+		}
+		atomic.AddInt64(&m.inflight, 1)
+		start := time.Now()
+		done := false
+		// 后续处理 panic 时同样记录, 状态码按 500 计
+		defer func() {
+			atomic.AddInt64(&m.inflight, -1)
+			route := con.Req.Pattern()
+			if route == "" {
+				route = _ROUTE_NOT_FOUND
+			}
+			size := con.Resp.Size()
+			if size < 0 {
+				size = 0
+			}
+			method := con.Req.Method
+			if !methods[method] {
+				method = _METHOD_OTHER
+			}
+			status := con.Resp.Status()
+			if !done {
+				status = http.StatusInternalServerError
+			}
+			m.observe(method, route, status, time.Since(start), size)
+		}()
+		con.Next()
+		done = true
+	}
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Path == "" {
+		opt.Path = "/metrics"
+	}
+	if opt.Namespace == "" {
+		opt.Namespace = "http"
+	}
+	if len(opt.Buckets) == 0 {
+		opt.Buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	} else {
+		opt.Buckets = append([]float64(nil), opt.Buckets...)
+	}
+	if len(opt.SizeBuckets) == 0 {
+		opt.SizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+	} else {
+		opt.SizeBuckets = append([]float64(nil), opt.SizeBuckets...)
+	}
+	sort.Float64s(opt.Buckets)
+	sort.Float64s(opt.SizeBuckets)
+	return opt
+}
+
+// ========================================================
+// metrics
+// ========================================================
+func (m *metrics) observe(method, route string, status int, d time.Duration, size int) {
+	labels := fmt.Sprintf(`method="%s",route="%s",status="%d"`, labelEscape(method), labelEscape(route), status)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s, ok := m.series[labels]
+	if !ok {
+		s = &series{
+			labels:   labels,
+			duration: histogramNew(m.opt.Buckets),
+			size:     histogramNew(m.opt.SizeBuckets),
+		}
+		m.series[labels] = s
+	}
+	s.requests++
+	s.duration.observe(d.Seconds())
+	s.size.observe(float64(size))
+}
+
+func (m *metrics) sessionsSet(count func() int) {
+	m.lock.Lock()
+	m.sessions = count
+	m.lock.Unlock()
+}
+
+func (m *metrics) expose() []byte {
+	ns := m.opt.Namespace
+	buf := new(bytes.Buffer)
+	m.lock.Lock()
+	list := make([]*series, 0, len(m.series))
+	for _, s := range m.series {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].labels < list[j].labels })
+	fmt.Fprintf(buf, "# HELP %s_requests_total Total number of HTTP requests.\n", ns)
+	fmt.Fprintf(buf, "# TYPE %s_requests_total counter\n", ns)
+	for _, s := range list {
+		fmt.Fprintf(buf, "%s_requests_total{%s} %d\n", ns, s.labels, s.requests)
+	}
+	fmt.Fprintf(buf, "# HELP %s_request_duration_seconds HTTP request latency.\n", ns)
+	fmt.Fprintf(buf, "# TYPE %s_request_duration_seconds histogram\n", ns)
+	for _, s := range list {
+		s.duration.write(buf, ns+"_request_duration_seconds", s.labels)
+	}
+	fmt.Fprintf(buf, "# HELP %s_response_size_bytes HTTP response size.\n", ns)
+	fmt.Fprintf(buf, "# TYPE %s_response_size_bytes histogram\n", ns)
+	for _, s := range list {
+		s.size.write(buf, ns+"_response_size_bytes", s.labels)
+	}
+	sessions := m.sessions
+	m.lock.Unlock()
+	fmt.Fprintf(buf, "# HELP %s_requests_in_flight HTTP requests being served.\n", ns)
+	fmt.Fprintf(buf, "# TYPE %s_requests_in_flight gauge\n", ns)
+	fmt.Fprintf(buf, "%s_requests_in_flight %d\n", ns, atomic.LoadInt64(&m.inflight))
+	if sessions != nil {
+		fmt.Fprintf(buf, "# HELP %s_sessions Number of sessions in the session provider.\n", ns)
+		fmt.Fprintf(buf, "# TYPE %s_sessions gauge\n", ns)
+		fmt.Fprintf(buf, "%s_sessions %d\n", ns, sessions())
+	}
+	hit, miss := cache.StatsGet()
+	fmt.Fprintf(buf, "# HELP %s_cache_hits_total Cache reads that found a value.\n", ns)
+	fmt.Fprintf(buf, "# TYPE %s_cache_hits_total counter\n", ns)
+	fmt.Fprintf(buf, "%s_cache_hits_total %d\n", ns, hit)
+	fmt.Fprintf(buf, "# HELP %s_cache_misses_total Cache reads that found nothing.\n", ns)
+	fmt.Fprintf(buf, "# TYPE %s_cache_misses_total counter\n", ns)
+	fmt.Fprintf(buf, "%s_cache_misses_total %d\n", ns, miss)
+	return buf.Bytes()
+}
+
+// ========================================================
+// histogram
+// ========================================================
+func histogramNew(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(buf *bytes.Buffer, name, labels string) {
+	for i, b := range h.buckets {
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(b, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// --------------------------------------------------------
+// FUNC
+// --------------------------------------------------------
+func labelEscape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
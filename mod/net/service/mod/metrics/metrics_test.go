@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/metrics"
+)
+
+func Test_Metrics(t *testing.T) {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(metrics.New())
+	ser.Rou.Get("/user/:id", func(con *service.Context) {
+		con.Ren.S(200, "user")
+	})
+	for _, url := range []string{"/user/1", "/user/2", "/none", "/metrics"} {
+		ser.Rou.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	}
+	for _, method := range []string{"FOO1", "FOO2"} {
+		ser.Rou.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/none", nil))
+	}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/user/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="NotFound",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/user/:id",status="200"} 2`,
+		`http_requests_total{method="OTHER",route="NotFound",status="404"} 2`,
+		`http_requests_in_flight 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "FOO1") {
+		t.Errorf("non-standard method exposed:\n%s", body)
+	}
+}
+
+// 后续处理 panic 时仍记录请求并减少进行中的数量, Buckets 不修改调用者的切片
+func Test_MetricsPanic(t *testing.T) {
+	buckets := []float64{1, 0.1}
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(metrics.New(metrics.Options{Buckets: buckets}))
+	ser.Rou.Get("/panic", func(con *service.Context) {
+		panic("boom")
+	})
+	func() {
+		defer func() { recover() }()
+		ser.Rou.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/panic",status="500"} 1`,
+		`http_requests_in_flight 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+	if buckets[0] != 1 {
+		t.Errorf("caller's Buckets sorted in place: %v", buckets)
+	}
+}
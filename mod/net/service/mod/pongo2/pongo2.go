@@ -1,15 +1,19 @@
 package pongo2
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"os"
-	"path/filepath"
-	"github.com/sail-services/sail-go/com/data/convert"
-	"github.com/sail-services/sail-go/mod/net/service"
+	"path"
 	"strings"
 	"sync"
 
+	"github.com/sail-services/sail-go/com/data/convert"
+	"github.com/sail-services/sail-go/mod/net/service"
+
 	"github.com/flosch/pongo2"
 )
 
@@ -25,6 +29,10 @@ type (
 		Dir     string   // 文件夹 [pongo2]
 		Ext     []string // 扩展名 [.html, .tpl]
 		Charset string   // 字符集 [UTF-8]
+		FS      fs.FS    // 模版文件系统, 如 embed.FS [os.DirFS(Dir)]
+	}
+	fsLoader struct {
+		fsys fs.FS
 	}
 )
 
@@ -90,20 +98,22 @@ func (ren *pongo2Render) do(status int) {
 // --------------------------------------------------------
 func pongo2Compile(opt *Options) map[string]*pongo2.Template {
 	tpl_map := make(map[string]*pongo2.Template)
-	if err := filepath.Walk(opt.Dir, func(path string, info os.FileInfo, err error) error {
-		r, err := filepath.Rel(opt.Dir, path)
+	set := pongo2.NewSet(opt.Dir, fsLoader{opt.FS})
+	if err := fs.WalkDir(opt.FS, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		ext := getExt(r)
+		if d.IsDir() {
+			return nil
+		}
+		ext := getExt(path)
 		for _, extension := range opt.Ext {
 			if ext == extension {
-				name := (r[0 : len(r)-len(ext)])
-				t, err := pongo2.FromFile(path)
+				t, err := set.FromFile(path)
 				if err != nil {
 					panic(fmt.Errorf("\"%s\": %v", path, err))
 				}
-				tpl_map[strings.Replace(name, "\\", "/", -1)] = t
+				tpl_map[path[:len(path)-len(ext)]] = t
 				break
 			}
 		}
@@ -128,6 +138,9 @@ func optPrepare(opts []Options) Options {
 	if opt.Charset == "" {
 		opt.Charset = "UTF-8"
 	}
+	if opt.FS == nil {
+		opt.FS = os.DirFS(opt.Dir)
+	}
 	return opt
 }
 
@@ -142,3 +155,22 @@ func getExt(s string) string {
 	}
 	return s[index:]
 }
+
+// ========================================================
+// fsLoader
+// ========================================================
+// include/extends 相对当前模版所在目录, 以 / 开头时相对根目录
+func (l fsLoader) Abs(base, name string) string {
+	if strings.HasPrefix(name, "/") || base == "" {
+		return strings.TrimPrefix(path.Clean("/"+name), "/")
+	}
+	return strings.TrimPrefix(path.Join("/", path.Dir(base), name), "/")
+}
+
+func (l fsLoader) Get(name string) (io.Reader, error) {
+	data, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+)
+
+type (
+	Options struct {
+		Algorithm string                            // 算法 token (令牌桶) / window (滑动窗口) [window]
+		Limit     int                               // 窗口内请求数 / 令牌桶容量 [60]
+		Period    time.Duration                     // 窗口长度 / 令牌桶填满所需时间 [1m]
+		Key       string                            // 限流依据 ip / session / header [ip]
+		KeyHeader string                            // Key 为 header 时的 Header 名 [X-API-Key]
+		KeyFunc   func(con *service.Context) string // 自定义限流依据, 返回空时不限流 [nil]
+		Prefix    string                            // 储存 Key 前缀 [_RATE_]
+		Cache     cache.Cache                       // 储存, 为空时使用 cache 模块 [nil]
+		ErrorFunc service.Handler                   // 超出限制时的处理 [429 文本]
+	}
+	result struct {
+		allowed   bool
+		remaining int
+		reset     time.Duration
+	}
+)
+
+const (
+	ALGORITHM_TOKEN  = "token"
+	ALGORITHM_WINDOW = "window"
+)
+
+func New(opts ...Options) service.Handler {
+	opt := optPrepare(opts)
+	return func(con *service.Context) {
+		key := opt.KeyFunc(con)
+		if key == "" {
+			return
+		}
+		c := opt.Cache
+		if c == nil {
+			c = cache.DataGetCache(con)
+		}
+		var r result
+		if opt.Algorithm == ALGORITHM_TOKEN {
+			r = tokenBucket(con.Req.Context(), c, opt, opt.Prefix+key, time.Now())
+		} else {
+			r = slidingWindow(con.Req.Context(), c, opt, opt.Prefix+key, time.Now())
+		}
+		hd := con.Resp.Header()
+		hd.Set("RateLimit-Limit", strconv.Itoa(opt.Limit))
+		hd.Set("RateLimit-Remaining", strconv.Itoa(r.remaining))
+		hd.Set("RateLimit-Reset", strconv.Itoa(seconds(r.reset)))
+		if r.allowed {
+			return
+		}
+		hd.Set("Retry-After", strconv.Itoa(seconds(r.reset)))
+		con.Abort(429)
+		opt.ErrorFunc(con)
+	}
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Algorithm == "" {
+		opt.Algorithm = ALGORITHM_WINDOW
+	}
+	if opt.Algorithm != ALGORITHM_WINDOW && opt.Algorithm != ALGORITHM_TOKEN {
+		panic("ratelimit: unknown algorithm " + opt.Algorithm)
+	}
+	if opt.Limit == 0 {
+		opt.Limit = 60
+	}
+	if opt.Period == 0 {
+		opt.Period = time.Minute
+	}
+	if opt.KeyHeader == "" {
+		opt.KeyHeader = "X-API-Key"
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = "_RATE_"
+	}
+	if opt.KeyFunc == nil {
+		switch opt.Key {
+		case "", "ip":
+			opt.KeyFunc = func(con *service.Context) string {
+				return "ip:" + con.Req.IP()
+			}
+		case "session":
+			opt.KeyFunc = func(con *service.Context) string {
+				return "session:" + session.DataGetStore(con).ID()
+			}
+		case "header":
+			opt.KeyFunc = func(con *service.Context) string {
+				if v := con.Req.Header.Get(opt.KeyHeader); v != "" {
+					return "header:" + v
+				}
+				return ""
+			}
+		default:
+			panic("ratelimit: unknown key " + opt.Key)
+		}
+	}
+	if opt.ErrorFunc == nil {
+		opt.ErrorFunc = func(con *service.Context) {
+			con.Ren.S(429, "429 Too Many Requests")
+		}
+	}
+	return opt
+}
+
+// 以当前与上一个固定窗口的计数按时间加权估算滑动窗口内的请求数
+func slidingWindow(ctx context.Context, c cache.Cache, opt Options, key string, now time.Time) result {
+	period := opt.Period.Nanoseconds()
+	window := now.UnixNano() / period
+	elapsed := float64(now.UnixNano()%period) / float64(period)
+	cur := key + ":" + strconv.FormatInt(window, 10)
+	prev := key + ":" + strconv.FormatInt(window-1, 10)
+	count := counterIncr(ctx, c, cur, 2*opt.Period)
+	var last int64
+	if v, err := c.Get(ctx, prev); err == nil {
+		last, _ = v.Int64()
+	}
+	estimate := float64(last)*(1-elapsed) + float64(count)
+	return result{
+		allowed:   estimate <= float64(opt.Limit),
+		remaining: int(math.Max(0, float64(opt.Limit)-estimate)),
+		reset:     time.Duration(float64(opt.Period) * (1 - elapsed)),
+	}
+}
+
+// 令牌桶状态以 "令牌数|时间" 储存, 多实例并发时为近似值
+func tokenBucket(ctx context.Context, c cache.Cache, opt Options, key string, now time.Time) result {
+	rate := float64(opt.Limit) / float64(opt.Period)
+	tokens := float64(opt.Limit)
+	if v, err := c.Get(ctx, key); err == nil {
+		var last int64
+		if _, err = fmt.Sscanf(v.String(), "%g|%d", &tokens, &last); err == nil {
+			tokens = math.Min(float64(opt.Limit), tokens+float64(now.UnixNano()-last)*rate)
+		} else {
+			tokens = float64(opt.Limit)
+		}
+	}
+	r := result{}
+	if tokens >= 1 {
+		tokens--
+		r.allowed = true
+		r.reset = time.Duration((float64(opt.Limit) - tokens) / rate)
+	} else {
+		r.reset = time.Duration((1 - tokens) / rate)
+	}
+	r.remaining = int(tokens)
+	c.Set(ctx, key, fmt.Sprintf("%g|%d", tokens, now.UnixNano()), opt.Period)
+	return r
+}
+
+// 计数在窗口的第一次请求时设置过期时间, 出错时按第一次请求处理
+func counterIncr(ctx context.Context, c cache.Cache, key string, ttl time.Duration) int64 {
+	n, err := c.Incr(ctx, key, 1)
+	if err != nil {
+		return 1
+	}
+	if n == 1 {
+		c.Touch(ctx, key, ttl)
+	}
+	return n
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
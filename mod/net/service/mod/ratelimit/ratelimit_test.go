@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/cache/memory"
+)
+
+func Test_SlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewMemoryCacher()
+	opt := optPrepare([]Options{{Limit: 3, Period: time.Minute}})
+	now := time.Unix(600, 0)
+	for i := 0; i < 3; i++ {
+		if r := slidingWindow(ctx, c, opt, "k", now); !r.allowed {
+			t.Fatalf("request %v should be allowed", i)
+		}
+	}
+	if r := slidingWindow(ctx, c, opt, "k", now); r.allowed || r.reset != time.Minute {
+		t.Errorf("4th request should be limited, got %+v", r)
+	}
+	if r := slidingWindow(ctx, c, opt, "k", now.Add(90*time.Second)); !r.allowed {
+		t.Errorf("request in later window should be allowed, got %+v", r)
+	}
+}
+
+func Test_TokenBucket(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewMemoryCacher()
+	opt := optPrepare([]Options{{Algorithm: ALGORITHM_TOKEN, Limit: 2, Period: 10 * time.Second}})
+	now := time.Unix(600, 0)
+	tokenBucket(ctx, c, opt, "k", now)
+	tokenBucket(ctx, c, opt, "k", now)
+	if r := tokenBucket(ctx, c, opt, "k", now); r.allowed || r.reset != 5*time.Second {
+		t.Errorf("empty bucket should be limited, got %+v", r)
+	}
+	if r := tokenBucket(ctx, c, opt, "k", now.Add(5*time.Second)); !r.allowed {
+		t.Errorf("refilled bucket should allow, got %+v", r)
+	}
+}
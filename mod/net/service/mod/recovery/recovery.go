@@ -3,10 +3,14 @@ package recovery
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"io/ioutil"
 	"net/http"
-	"github.com/sail-services/sail-go/mod/net/service"
 	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sail-services/sail-go/mod/net/service"
 )
 
 const (
@@ -46,54 +50,120 @@ pre {
 </html>`
 )
 
+type (
+	Options struct {
+		PanicHandler func(con *service.Context, err interface{}, stack []byte) // 上报等处理, 在返回 500 前调用 [nil]
+		JSONPaths    []string                                                  // 以 JSON 返回的路径前缀, 如 /api [nil]
+		ShowStack    bool                                                      // 非开发模式下也在页面中显示调用栈 [false]
+	}
+	panicJSON struct {
+		Error string `json:"error"`
+		Panic string `json:"panic,omitempty"`
+		Stack string `json:"stack,omitempty"`
+	}
+)
+
 var (
 	dunno      = []byte("???")
 	center_dot = []byte("·")
 	dot        = []byte(".")
 	slash      = []byte("/")
+
+	sources sync.Map // 文件名 -> [][]byte
 )
 
-func New() service.Handler {
+// 捕获 panic 并记录错误日志, 返回 500; 返回内容已开始写入时只记录不再写入
+func New(opts ...Options) service.Handler {
+	opt := optPrepare(opts)
 	return func(con *service.Context) {
+		resp := con.Resp
 		defer func() {
-			if err := recover(); err != nil {
-				stack := stack(3)
-				con.Log.Fatalf("<PANIC> %s\n%s\n", stack, err)
-				con.Resp.WriteHeader(http.StatusInternalServerError)
-				if service.ModeIsDev() {
-					con.Resp.Header().Set("Content-Type", "text/html")
-					body := []byte(fmt.Sprintf(panicHTML, err, err, stack))
-					con.Resp.Write(body)
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			stack := stack(3)
+			con.Log.Errorf("<PANIC> %s\n%s\n", err, stack)
+			if opt.PanicHandler != nil {
+				opt.PanicHandler(con, err, stack)
+			}
+			// etag/encoding/page 等模块替换的 Resp 在 panic 后不会再输出, 恢复原 Resp 返回 500
+			con.Resp = resp
+			if con.Resp.IsWritten() {
+				con.Abort(0)
+				return
+			}
+			// 清除出错前设置的内容相关 Header
+			hd := con.Resp.Header()
+			for _, name := range []string{"Content-Type", "Content-Encoding", "Content-Length", "ETag"} {
+				hd.Del(name)
+			}
+			con.Abort(http.StatusInternalServerError)
+			show := opt.ShowStack || service.ModeIsDev()
+			if jsonWant(con, opt) {
+				v := panicJSON{Error: http.StatusText(http.StatusInternalServerError)}
+				if show {
+					v.Panic, v.Stack = fmt.Sprint(err), string(stack)
 				}
+				con.Ren.JSON(http.StatusInternalServerError, v)
+			} else if show {
+				msg := html.EscapeString(fmt.Sprint(err))
+				con.Ren.HTML(http.StatusInternalServerError, []byte(fmt.Sprintf(panicHTML, msg, msg, html.EscapeString(string(stack)))))
+			} else {
+				con.Ren.S(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 			}
 		}()
 		con.Next()
 	}
 }
 
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	return opt
+}
+
+func jsonWant(con *service.Context, opt Options) bool {
+	for _, prefix := range opt.JSONPaths {
+		if strings.HasPrefix(con.Req.URL.Path, prefix) {
+			return true
+		}
+	}
+	accept := con.Req.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
 func stack(skip int) []byte {
 	buf := new(bytes.Buffer)
-	var lines [][]byte
-	var lastFile string
 	for i := skip; ; i++ {
 		pc, file, line, ok := runtime.Caller(i)
 		if !ok {
 			break
 		}
 		fmt.Fprintf(buf, "%s:%d (0x%x)\n", file, line, pc)
-		if file != lastFile {
-			data, err := ioutil.ReadFile(file)
-			if err != nil {
-				continue
-			}
-			lines = bytes.Split(data, []byte{'\n'})
-			lastFile = file
-		}
-		fmt.Fprintf(buf, "\t%s: %s\n", function(pc), source(lines, line))
+		fmt.Fprintf(buf, "\t%s: %s\n", function(pc), source(sourceGet(file), line))
 	}
 	return buf.Bytes()
 }
 
+// 源文件只读取一次, 读取失败同样缓存, 避免每次 panic 重复访问磁盘
+func sourceGet(file string) [][]byte {
+	if lines, ok := sources.Load(file); ok {
+		return lines.([][]byte)
+	}
+	var lines [][]byte
+	if data, err := ioutil.ReadFile(file); err == nil {
+		lines = bytes.Split(data, []byte{'\n'})
+	}
+	sources.Store(file, lines)
+	return lines
+}
+
 func source(lines [][]byte, n int) []byte {
 	n--
 	if n < 0 || n >= len(lines) {
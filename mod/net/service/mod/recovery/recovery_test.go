@@ -0,0 +1,78 @@
+package recovery_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/encoding"
+	"github.com/sail-services/sail-go/mod/net/service/mod/etag"
+	"github.com/sail-services/sail-go/mod/net/service/mod/recovery"
+)
+
+func serviceNew(opt recovery.Options) *service.Service {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(recovery.New(opt))
+	ser.Rou.Get("/api/panic", func(con *service.Context) {
+		panic("boom")
+	})
+	ser.Rou.Get("/partial", func(con *service.Context) {
+		con.Ren.S(200, "partial")
+		panic("late")
+	})
+	return ser
+}
+
+func Test_Recovery(t *testing.T) {
+	var reported interface{}
+	ser := serviceNew(recovery.Options{
+		JSONPaths: []string{"/api"},
+		PanicHandler: func(con *service.Context, err interface{}, stack []byte) {
+			reported = err
+		},
+	})
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/api/panic", nil))
+	if rec.Code != 500 || reported != "boom" {
+		t.Fatalf("code %d, reported %v", rec.Code, reported)
+	}
+	var v map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil || v["error"] != "Internal Server Error" {
+		t.Errorf("json body %q", rec.Body.String())
+	}
+	if !strings.Contains(v["stack"], "recovery_test.go") {
+		t.Errorf("stack missing in dev mode: %q", v["stack"])
+	}
+}
+
+func Test_RecoveryWritten(t *testing.T) {
+	ser := serviceNew(recovery.Options{})
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/partial", nil))
+	if rec.Code != 200 || rec.Body.String() != "partial" {
+		t.Errorf("code %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+func Test_RecoveryWrapped(t *testing.T) {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(recovery.New())
+	ser.Module(encoding.New())
+	ser.Module(etag.New())
+	ser.Rou.Get("/partial", func(con *service.Context) {
+		con.Ren.S(200, strings.Repeat("partial", 200))
+		panic("late")
+	})
+	req := httptest.NewRequest("GET", "/partial", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	if rec.Code != 500 || !strings.Contains(rec.Body.String(), "PANIC: late") || strings.Contains(rec.Body.String(), "partialpartial") ||
+		!strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("code %d, header %v, body %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
@@ -0,0 +1,68 @@
+package requestid
+
+import (
+	"github.com/sail-services/sail-go/com/data/uuid"
+	"github.com/sail-services/sail-go/mod/net/fetcher"
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	Options struct {
+		Header    string // 请求与返回的 Header 名 [X-Request-ID]
+		NoLogging bool   // 不在 con.Log 中加入请求 ID [false]
+	}
+)
+
+const (
+	_DATA_REQUEST_ID = "_DATA_REQUEST_ID"
+	_ID_MAX_LENGTH   = 128
+)
+
+// 读取或生成请求 ID, 写入返回 Header 并作为 con.Log 的前缀.
+// 请求 ID 同时保存在 con.Req 的 Context 中, 用该 Context 调用 fetcher 时转发
+func New(opts ...Options) service.Handler {
+	opt := optPrepare(opts)
+	return func(con *service.Context) {
+		id := con.Req.Header.Get(opt.Header)
+		if !idValid(id) {
+			id = uuid.NewV4().S()
+		}
+		con.DataSet(_DATA_REQUEST_ID, id)
+		con.Req.Request = con.Req.WithContext(fetcher.ContextWithRequestID(con.Req.Context(), id))
+		con.Resp.Header().Set(opt.Header, id)
+		if !opt.NoLogging {
+			con.Log = con.Log.PrefixNew("<" + id + "> ")
+		}
+	}
+}
+
+func DataGetID(con *service.Context) string {
+	if id, ok := con.DataGet(_DATA_REQUEST_ID); ok {
+		return id.(string)
+	}
+	return ""
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Header == "" {
+		opt.Header = "X-Request-ID"
+	}
+	return opt
+}
+
+// 只接受长度有限的可见 ASCII, 避免日志注入
+func idValid(id string) bool {
+	if id == "" || len(id) > _ID_MAX_LENGTH {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
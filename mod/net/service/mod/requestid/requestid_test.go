@@ -0,0 +1,92 @@
+package requestid_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/fetcher"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/requestid"
+)
+
+func Test_RequestID(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ser := service.New(log.New(buf, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(requestid.New())
+	var id string
+	ser.Rou.Get("/", func(con *service.Context) {
+		id = requestid.DataGetID(con)
+		con.Log.Info("handled")
+		con.Ren.S(200, "ok")
+	})
+	do := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set("X-Request-ID", header)
+		}
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := do("abc-123")
+	if id != "abc-123" || rec.Header().Get("X-Request-ID") != "abc-123" {
+		t.Errorf("incoming id not kept: %q %q", id, rec.Header().Get("X-Request-ID"))
+	}
+	if !strings.Contains(buf.String(), "<abc-123> ") {
+		t.Errorf("log not prefixed: %q", buf.String())
+	}
+	for _, bad := range []string{"", "a b", "x\ny", strings.Repeat("a", 129)} {
+		rec = do(bad)
+		if id == bad || len(id) != 36 || rec.Header().Get("X-Request-ID") != id {
+			t.Errorf("id for %q = %q, header %q", bad, id, rec.Header().Get("X-Request-ID"))
+		}
+	}
+}
+
+func Test_RequestIDOptions(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ser := service.New(log.New(buf, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(requestid.New(requestid.Options{Header: "X-Trace", NoLogging: true}))
+	ser.Rou.Get("/", func(con *service.Context) {
+		con.Log.Info("handled")
+		con.Ren.S(200, "ok")
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Trace", "t1")
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	if rec.Header().Get("X-Trace") != "t1" || rec.Header().Get("X-Request-ID") != "" {
+		t.Errorf("header = %v", rec.Header())
+	}
+	if strings.Contains(buf.String(), "<t1>") {
+		t.Errorf("log prefixed with NoLogging: %q", buf.String())
+	}
+}
+
+// 用请求的 Context 调用 fetcher 时转发请求 ID
+func Test_RequestIDForward(t *testing.T) {
+	var got string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Request-ID")
+	}))
+	defer upstream.Close()
+	ser := service.New(log.New(new(bytes.Buffer), log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(requestid.New())
+	ser.Rou.Get("/", func(con *service.Context) {
+		f := fetcher.New(strings.TrimPrefix(upstream.URL, "http://"))
+		if _, err := f.GetContext(con.Req.Context(), "/"); err != nil {
+			t.Errorf("GetContext: %v", err)
+		}
+		con.Ren.S(200, "ok")
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "fwd-1")
+	ser.Rou.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "fwd-1" {
+		t.Errorf("forwarded X-Request-ID = %q", got)
+	}
+}
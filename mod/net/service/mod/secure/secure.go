@@ -0,0 +1,127 @@
+package secure
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	Options struct {
+		HSTSMaxAge        int    // HSTS 秒数, -1 不设置 [31536000]
+		HSTSSubdomains    bool   // HSTS 包含子域名 [false]
+		HSTSPreload       bool   // HSTS preload [false]
+		NoSniffDisabled   bool   // 不设置 X-Content-Type-Options: nosniff [false]
+		FrameOptions      string // X-Frame-Options, - 不设置 [SAMEORIGIN]
+		ReferrerPolicy    string // Referrer-Policy, - 不设置 [strict-origin-when-cross-origin]
+		PermissionsPolicy string // Permissions-Policy [nil]
+		CSP               string // Content-Security-Policy, {nonce} 替换为每次请求的随机值 [nil]
+		CSPReportOnly     bool   // 只报告不拦截 [false]
+		CSPReportOnlyDev  bool   // 开发模式下只报告不拦截 [false]
+		NonceVar          string // 模版中 nonce 的变量名 [NONCE]
+		SSLRedirect       bool   // HTTP 跳转到 HTTPS [false]
+		SSLHost           string // 跳转的 Host, 为空时使用请求 Host [nil]
+	}
+)
+
+const (
+	_DATA_SECURE_NONCE = "_DATA_SECURE_NONCE"
+)
+
+func New(opts ...Options) service.Handler {
+	opt := optPrepare(opts)
+	csp_header := "Content-Security-Policy"
+	if opt.CSPReportOnly || (opt.CSPReportOnlyDev && service.ModeIsDev()) {
+		csp_header = "Content-Security-Policy-Report-Only"
+	}
+	hsts := ""
+	if opt.HSTSMaxAge >= 0 {
+		hsts = "max-age=" + strconv.Itoa(opt.HSTSMaxAge)
+		if opt.HSTSSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if opt.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	return func(con *service.Context) {
+		https := IsHTTPS(con.Req.Request)
+		if opt.SSLRedirect && !https {
+			host := opt.SSLHost
+			if host == "" {
+				host = con.Req.Host
+			}
+			con.Ren.Redirect(http.StatusMovedPermanently, "https://"+host+con.Req.RequestURI)
+			con.Abort(0)
+			return
+		}
+		hd := con.Resp.Header()
+		if https && hsts != "" {
+			hd.Set("Strict-Transport-Security", hsts)
+		}
+		if !opt.NoSniffDisabled {
+			hd.Set("X-Content-Type-Options", "nosniff")
+		}
+		if opt.FrameOptions != "-" {
+			hd.Set("X-Frame-Options", opt.FrameOptions)
+		}
+		if opt.ReferrerPolicy != "-" {
+			hd.Set("Referrer-Policy", opt.ReferrerPolicy)
+		}
+		if opt.PermissionsPolicy != "" {
+			hd.Set("Permissions-Policy", opt.PermissionsPolicy)
+		}
+		if opt.CSP != "" {
+			csp := opt.CSP
+			if strings.Contains(csp, "{nonce}") {
+				nonce := nonceNew()
+				con.DataSet(_DATA_SECURE_NONCE, nonce)
+				con.Var[opt.NonceVar] = nonce
+				csp = strings.Replace(csp, "{nonce}", nonce, -1)
+			}
+			hd.Set(csp_header, csp)
+		}
+	}
+}
+
+// 当前请求的 CSP nonce, 未使用 {nonce} 时为空
+func DataGetNonce(con *service.Context) string {
+	if nonce, ok := con.DataGet(_DATA_SECURE_NONCE); ok {
+		return nonce.(string)
+	}
+	return ""
+}
+
+func IsHTTPS(req *http.Request) bool {
+	return req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func optPrepare(opts []Options) Options {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.HSTSMaxAge == 0 {
+		opt.HSTSMaxAge = 365 * 24 * 60 * 60
+	}
+	if opt.FrameOptions == "" {
+		opt.FrameOptions = "SAMEORIGIN"
+	}
+	if opt.ReferrerPolicy == "" {
+		opt.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	if opt.NonceVar == "" {
+		opt.NonceVar = "NONCE"
+	}
+	return opt
+}
+
+func nonceNew() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,82 @@
+package secure_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/secure"
+)
+
+func serviceNew(opt secure.Options, nonces *[]string) *service.Service {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(secure.New(opt))
+	ser.Rou.Get("/", func(con *service.Context) {
+		*nonces = append(*nonces, secure.DataGetNonce(con))
+		if v, _ := con.Var["NONCE"].(string); v != secure.DataGetNonce(con) {
+			con.Ren.S(500, "nonce var mismatch")
+			return
+		}
+		con.Ren.S(200, "ok")
+	})
+	return ser
+}
+
+func Test_Headers(t *testing.T) {
+	var nonces []string
+	ser := serviceNew(secure.Options{}, &nonces)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	hd := rec.Header()
+	for name, want := range map[string]string{
+		"Strict-Transport-Security": "max-age=31536000",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "SAMEORIGIN",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Content-Security-Policy":   "",
+	} {
+		if got := hd.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	ser = serviceNew(secure.Options{NoSniffDisabled: true, FrameOptions: "-", ReferrerPolicy: "-", HSTSMaxAge: -1}, &nonces)
+	rec = httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	for _, name := range []string{"Strict-Transport-Security", "X-Content-Type-Options", "X-Frame-Options", "Referrer-Policy"} {
+		if got := rec.Header().Get(name); got != "" {
+			t.Errorf("disabled %s = %q", name, got)
+		}
+	}
+	// HTTP 请求不设置 HSTS
+	ser = serviceNew(secure.Options{}, &nonces)
+	rec = httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS over http = %q", got)
+	}
+}
+
+func Test_CSPNonce(t *testing.T) {
+	var nonces []string
+	ser := serviceNew(secure.Options{CSP: "script-src 'nonce-{nonce}'", CSPReportOnly: true}, &nonces)
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 200 {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+		}
+		csp := rec.Header().Get("Content-Security-Policy-Report-Only")
+		if nonces[i] == "" || csp != "script-src 'nonce-"+nonces[i]+"'" || strings.Contains(csp, "{nonce}") {
+			t.Errorf("csp = %q, nonce = %q", csp, nonces[i])
+		}
+	}
+	if nonces[0] == nonces[1] {
+		t.Errorf("nonce reused across requests: %q", nonces[0])
+	}
+}
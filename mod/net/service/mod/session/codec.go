@@ -0,0 +1,89 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Codec serializes session data for providers that save it as bytes.
+type Codec interface {
+	// Encode encodes session data.
+	Encode(map[interface{}]interface{}) ([]byte, error)
+	// Decode decodes session data.
+	Decode([]byte) (map[interface{}]interface{}, error)
+}
+
+// CodecProvider is implemented by providers that serialize session data,
+// Manager sets the codec chosen by Options.Codec before Init.
+type CodecProvider interface {
+	Provider
+	// CodecSet sets codec of session data.
+	CodecSet(Codec)
+}
+
+const (
+	CODEC_GOB     = "gob"
+	CODEC_JSON    = "json"
+	CODEC_MSGPACK = "msgpack"
+)
+
+var (
+	codecs = map[string]Codec{
+		CODEC_GOB:  GobCodec{},
+		CODEC_JSON: JSONCodec{},
+	}
+)
+
+// RegisterCodec registers a codec, msgpack is registered by session/msgpack package.
+func RegisterCodec(name string, codec Codec) {
+	if codec == nil {
+		panic("session: cannot register codec with nil value")
+	}
+	if _, dup := codecs[name]; dup {
+		panic(fmt.Errorf("session: cannot register codec '%s' twice", name))
+	}
+	codecs[name] = codec
+}
+
+// GobCodec encodes session data with encoding/gob, values keep their Go types
+// but the types must stay the same between deploys.
+type GobCodec struct{}
+
+func (GobCodec) Encode(kv map[interface{}]interface{}) ([]byte, error) {
+	return EncodeGob(kv)
+}
+
+func (GobCodec) Decode(data []byte) (map[interface{}]interface{}, error) {
+	return DecodeGob(data)
+}
+
+// JSONCodec encodes session data as a JSON object, keys must be strings.
+// Numbers are decoded as json.Number, use typed getters of Store to read values.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(kv map[interface{}]interface{}) ([]byte, error) {
+	obj := make(map[string]interface{}, len(kv))
+	for k, v := range kv {
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("session(json): key %v is %T, not string", k, k)
+		}
+		obj[key] = v
+	}
+	return json.Marshal(obj)
+}
+
+func (JSONCodec) Decode(data []byte) (map[interface{}]interface{}, error) {
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	kv := make(map[interface{}]interface{}, len(obj))
+	for k, v := range obj {
+		kv[k] = v
+	}
+	return kv, nil
+}
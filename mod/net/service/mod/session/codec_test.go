@@ -0,0 +1,86 @@
+package session
+
+import (
+	"testing"
+)
+
+type mapStore map[interface{}]interface{}
+
+func (m mapStore) Set(k, v interface{}) error    { m[k] = v; return nil }
+func (m mapStore) Get(k interface{}) interface{} { return m[k] }
+func (m mapStore) Delete(k interface{}) error    { delete(m, k); return nil }
+func (m mapStore) ID() string                    { return "test" }
+func (m mapStore) Release() error                { return nil }
+func (m mapStore) Flush() error                  { return nil }
+
+type user struct {
+	Name  string
+	Roles []string
+}
+
+func Test_Codec(t *testing.T) {
+	kv := map[interface{}]interface{}{
+		"name":  "alice",
+		"id":    int64(1) << 53,
+		"ratio": 0.5,
+		"admin": true,
+		"user":  user{"alice", []string{"a", "b"}},
+	}
+	for name, codec := range codecs {
+		data, err := codec.Encode(kv)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", name, err)
+		}
+		out, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", name, err)
+		}
+		s := &store{RawStore: mapStore(out)}
+		if v := s.GetString("name"); v != "alice" {
+			t.Errorf("%s: GetString = %q", name, v)
+		}
+		if v := s.GetInt64("id"); v != int64(1)<<53 {
+			t.Errorf("%s: GetInt64 = %d", name, v)
+		}
+		if v := s.GetFloat64("ratio"); v != 0.5 {
+			t.Errorf("%s: GetFloat64 = %v", name, v)
+		}
+		if !s.GetBool("admin") {
+			t.Errorf("%s: GetBool = false", name)
+		}
+		var u user
+		if err = s.GetStruct("user", &u); err != nil || u.Name != "alice" || len(u.Roles) != 2 {
+			t.Errorf("%s: GetStruct = %+v, %v", name, u, err)
+		}
+	}
+
+	if _, err := (JSONCodec{}).Encode(map[interface{}]interface{}{1: "x"}); err == nil {
+		t.Errorf("json: non-string key accepted")
+	}
+}
+
+func Test_StoreTyped(t *testing.T) {
+	s := &store{RawStore: mapStore{"n": 3, "s": "42", "u": &user{Name: "bob"}, "once": "msg"}}
+	if s.GetInt("n") != 3 || s.GetInt("s") != 42 || s.GetInt("missing") != 0 {
+		t.Errorf("GetInt: %d %d %d", s.GetInt("n"), s.GetInt("s"), s.GetInt("missing"))
+	}
+	if s.GetString("n") != "3" || s.GetString("missing") != "" {
+		t.Errorf("GetString: %q", s.GetString("n"))
+	}
+	var u user
+	if err := s.GetStruct("u", &u); err != nil || u.Name != "bob" {
+		t.Errorf("GetStruct from pointer = %+v, %v", u, err)
+	}
+	if err := s.GetStruct("missing", &u); err != ErrKeyNotFound {
+		t.Errorf("GetStruct missing = %v", err)
+	}
+	if err := s.GetStruct("u", u); err == nil {
+		t.Errorf("GetStruct accepted non-pointer")
+	}
+	if v := s.Pop("once"); v != "msg" {
+		t.Errorf("Pop = %v", v)
+	}
+	if v := s.Get("once"); v != nil {
+		t.Errorf("value %v after Pop", v)
+	}
+}
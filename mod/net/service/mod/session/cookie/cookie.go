@@ -0,0 +1,279 @@
+package cookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+)
+
+// CookieStore represents a session store kept in an encrypted cookie.
+type CookieStore struct {
+	p       *CookieProvider
+	sid     string
+	lock    sync.RWMutex
+	data    map[interface{}]interface{}
+	expires int64
+	changed bool
+}
+
+// CookieProvider represents a session provider keeping data in encrypted, authenticated cookies.
+// Config is a comma separated key list: the first key encrypts, all keys decrypt.
+type CookieProvider struct {
+	maxLifetime int64
+	aeads       []cipher.AEAD
+	codec       session.Codec
+}
+
+const (
+	// MaxSize is the largest encoded cookie value, leaving room for the other cookie attributes.
+	MaxSize = 4000
+
+	_ID_LENGTH = 16
+)
+
+var (
+	ErrTooLarge = errors.New("session(cookie): encoded session exceeds cookie size limit")
+)
+
+// Set sets value to given key in session, returns ErrTooLarge if the session would not fit in the cookie.
+func (s *CookieStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old, had := s.data[key]
+	s.data[key] = val
+	if err := s.p.sizeCheck(s); err != nil {
+		if had {
+			s.data[key] = old
+		} else {
+			delete(s.data, key)
+		}
+		return err
+	}
+	s.changed = true
+	return nil
+}
+
+// Get gets value by given key in session.
+func (s *CookieStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+// Delete deletes a key from session.
+func (s *CookieStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.data[key]; ok {
+		delete(s.data, key)
+		s.changed = true
+	}
+	return nil
+}
+
+// ID returns current session ID.
+func (s *CookieStore) ID() string {
+	return s.sid
+}
+
+// IDSet changes session ID, used by RegenerateId.
+func (s *CookieStore) IDSet(sid string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sid = sid
+	s.changed = true
+}
+
+// Release does nothing, data is written to cookie before the response header.
+func (_ *CookieStore) Release() error {
+	return nil
+}
+
+// Flush deletes all session data.
+func (s *CookieStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	s.changed = true
+	return nil
+}
+
+// Init initializes cookie session provider.
+func (p *CookieProvider) Init(maxLifetime int64, config string) error {
+	p.maxLifetime = maxLifetime
+	if p.codec == nil {
+		p.codec = session.GobCodec{}
+	}
+	p.aeads = nil
+	keys := strings.Split(config, ",")
+	if strings.TrimSpace(config) == "" {
+		keys = []string{service.SecretKeyGet()}
+	}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return errors.New("session(cookie): empty key, set Conn or service secret key")
+		}
+		sum := sha256.Sum256([]byte(key))
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		p.aeads = append(p.aeads, aead)
+	}
+	return nil
+}
+
+// CodecSet sets codec of session data.
+func (p *CookieProvider) CodecSet(codec session.Codec) {
+	p.codec = codec
+}
+
+// Read decodes session from cookie value, returns a new session if value is invalid or expired.
+func (p *CookieProvider) Read(value string) (session.RawStore, error) {
+	if s, err := p.decode(value); err == nil {
+		return s, nil
+	}
+	return &CookieStore{p: p, sid: idNew(), data: make(map[interface{}]interface{})}, nil
+}
+
+// Exist returns true if cookie value is a valid session.
+func (p *CookieProvider) Exist(value string) bool {
+	_, err := p.decode(value)
+	return err == nil
+}
+
+// Destory does nothing, the cookie is removed when session is flushed.
+func (p *CookieProvider) Destory(_ string) error {
+	return nil
+}
+
+// Regenerate returns session decoded from old cookie value with new ID.
+func (p *CookieProvider) Regenerate(oldvalue, sid string) (session.RawStore, error) {
+	s, err := p.Read(oldvalue)
+	if err != nil {
+		return nil, err
+	}
+	s.(*CookieStore).IDSet(sid)
+	return s, nil
+}
+
+// Count is unknown for cookie sessions and always returns 0.
+func (p *CookieProvider) Count() int {
+	return 0
+}
+
+// GC does nothing, expired cookies are rejected on read.
+func (p *CookieProvider) GC() {}
+
+// Encode returns encrypted cookie value with refreshed expiry.
+func (p *CookieProvider) Encode(raw session.RawStore) (string, bool, error) {
+	s, ok := raw.(*CookieStore)
+	if !ok {
+		return "", false, fmt.Errorf("session(cookie): unknown store %T", raw)
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if !s.changed {
+		return "", false, nil
+	}
+	if len(s.data) == 0 {
+		return "", true, nil
+	}
+	data, err := p.codec.Encode(s.data)
+	if err != nil {
+		return "", false, err
+	}
+	// 过期时间 (8) + ID 长度 (1) + ID + 数据
+	plain := make([]byte, 9, 9+len(s.sid)+len(data))
+	binary.BigEndian.PutUint64(plain, uint64(time.Now().Unix()+p.maxLifetime))
+	plain[8] = byte(len(s.sid))
+	plain = append(append(plain, s.sid...), data...)
+	aead := p.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", false, err
+	}
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil))
+	if len(value) > MaxSize {
+		return "", false, ErrTooLarge
+	}
+	return value, true, nil
+}
+
+// sizeCheck returns ErrTooLarge if the encoded cookie of s exceeds MaxSize, s must be locked.
+func (p *CookieProvider) sizeCheck(s *CookieStore) error {
+	data, err := p.codec.Encode(s.data)
+	if err != nil {
+		return err
+	}
+	aead := p.aeads[0]
+	n := aead.NonceSize() + 9 + len(s.sid) + len(data) + aead.Overhead()
+	if base64.RawURLEncoding.EncodedLen(n) > MaxSize {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+func (p *CookieProvider) decode(value string) (*CookieStore, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || value == "" {
+		return nil, errors.New("session(cookie): bad encoding")
+	}
+	for i, aead := range p.aeads {
+		if len(data) < aead.NonceSize() {
+			break
+		}
+		plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+		if err != nil {
+			continue
+		}
+		if len(plain) < 9 || len(plain) < 9+int(plain[8]) {
+			return nil, errors.New("session(cookie): bad payload")
+		}
+		if int64(binary.BigEndian.Uint64(plain)) < time.Now().Unix() {
+			return nil, errors.New("session(cookie): expired")
+		}
+		s := &CookieStore{p: p, sid: string(plain[9 : 9+int(plain[8])])}
+		if s.data, err = p.codec.Decode(plain[9+int(plain[8]):]); err != nil {
+			return nil, err
+		}
+		// 旧密钥加密的会话在下次返回时用新密钥重新加密
+		s.changed = i > 0
+		return s, nil
+	}
+	return nil, errors.New("session(cookie): bad signature")
+}
+
+func idNew() string {
+	b := make([]byte, _ID_LENGTH/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func init() {
+	session.Register("cookie", &CookieProvider{})
+}
@@ -0,0 +1,104 @@
+package cookie_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session/cookie"
+)
+
+func serviceNew(keys string) *service.Service {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(session.New(session.Options{Adapter: "cookie", Conn: keys}))
+	ser.Rou.Get("/set", func(con *service.Context) {
+		if err := session.DataGetStore(con).Set("user", con.Req.FormValue("v")); err != nil {
+			con.Ren.S(400, err.Error())
+			return
+		}
+		con.Ren.S(200, "ok")
+	})
+	ser.Rou.Get("/get", func(con *service.Context) {
+		v, _ := session.DataGetStore(con).Get("user").(string)
+		con.Ren.S(200, v)
+	})
+	ser.Rou.Get("/edge", func(con *service.Context) {
+		store := session.DataGetStore(con)
+		n := 2000
+		for store.Set("user", strings.Repeat("a", n)) == nil {
+			n++
+		}
+		store.Set("user", strings.Repeat("a", n-1))
+		con.Ren.S(200, "ok")
+	})
+	ser.Rou.Get("/logout", func(con *service.Context) {
+		session.DataGetStore(con).Destory(con)
+		con.Ren.S(200, "bye")
+	})
+	return ser
+}
+
+func do(ser *service.Service, url string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", url, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	return rec
+}
+
+func cookies(rec *httptest.ResponseRecorder) []*http.Cookie {
+	return (&http.Response{Header: rec.Header()}).Cookies()
+}
+
+func Test_Cookie(t *testing.T) {
+	ser := serviceNew("key1")
+	if rec := do(ser, "/get", nil); len(cookies(rec)) != 0 {
+		t.Errorf("empty session wrote cookie: %v", rec.Header())
+	}
+	cs := cookies(do(ser, "/set?v=alice", nil))
+	if len(cs) != 1 || cs[0].Value == "" || strings.Contains(cs[0].Value, "alice") {
+		t.Fatalf("cookie: %v", cs)
+	}
+	if body := do(ser, "/get", cs).Body.String(); body != "alice" {
+		t.Errorf("read back %q", body)
+	}
+	tampered := *cs[0]
+	tampered.Value = "x" + tampered.Value[1:]
+	if body := do(ser, "/get", []*http.Cookie{&tampered}).Body.String(); body != "" {
+		t.Errorf("tampered cookie accepted: %q", body)
+	}
+	if out := cookies(do(ser, "/logout", cs)); len(out) != 1 || out[0].MaxAge != -1 {
+		t.Errorf("logout did not clear cookie: %v", out)
+	}
+}
+
+func Test_KeyRotation(t *testing.T) {
+	cs := cookies(do(serviceNew("old"), "/set?v=bob", nil))
+	ser := serviceNew("new,old")
+	rec := do(ser, "/get", cs)
+	if rec.Body.String() != "bob" {
+		t.Fatalf("old key not accepted: %q", rec.Body.String())
+	}
+	if out := cookies(rec); len(out) != 1 {
+		t.Errorf("session not re-encrypted with new key: %v", out)
+	}
+}
+
+func Test_SizeLimit(t *testing.T) {
+	ser := serviceNew("key1")
+	rec := do(ser, "/set?v="+strings.Repeat("a", 5000), nil)
+	if cs := cookies(rec); rec.Code != 400 || rec.Body.String() != cookie.ErrTooLarge.Error() || len(cs) != 0 {
+		t.Errorf("oversized session: %d %q %v", rec.Code, rec.Body.String(), cs)
+	}
+	// 接近上限时 Set 成功, 写入 Cookie 时加上创建时间后超出, 返回 500
+	if rec = do(ser, "/edge", nil); rec.Code != 500 || len(cookies(rec)) != 0 {
+		t.Errorf("session over limit at write: %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,374 @@
+package file
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+)
+
+// FileStore represents a file session store implementation.
+type FileStore struct {
+	p    *FileProvider
+	sid  string
+	lock sync.RWMutex
+	data map[interface{}]interface{}
+}
+
+// FileProvider represents a file session provider implementation.
+// Each session is a file at <root>/<sid[0]>/<sid[1]>/<sid>, written atomically.
+// User index is kept as empty files at <root>/_index/<sha1(uid)>/<sid>.
+type FileProvider struct {
+	lock        sync.RWMutex
+	maxlifetime int64
+	root        string
+	codec       session.Codec
+}
+
+const (
+	_ROOT_DEFAULT = "data/session"
+	_TEMP_SUFFIX  = ".tmp"
+	_INDEX_DIR    = "_index"
+)
+
+// Set sets value to given key in session.
+func (s *FileStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data[key] = val
+	return nil
+}
+
+// Get gets value by given key in session.
+func (s *FileStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+// Delete deletes a key from session.
+func (s *FileStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+// ID returns current session ID.
+func (s *FileStore) ID() string {
+	return s.sid
+}
+
+// Release writes session data to file.
+func (s *FileStore) Release() error {
+	s.lock.RLock()
+	data, err := s.p.codec.Encode(s.data)
+	s.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	s.p.lock.Lock()
+	defer s.p.lock.Unlock()
+	return s.p.write(s.sid, data)
+}
+
+// Flush deletes all session data.
+func (s *FileStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	return nil
+}
+
+// Init initializes file session provider.
+// config: directory of session files, default is data/session.
+func (p *FileProvider) Init(maxlifetime int64, config string) error {
+	p.maxlifetime = maxlifetime
+	if p.codec == nil {
+		p.codec = session.GobCodec{}
+	}
+	p.root = config
+	if p.root == "" {
+		p.root = _ROOT_DEFAULT
+	}
+	return os.MkdirAll(p.root, 0700)
+}
+
+// CodecSet sets codec of session data.
+func (p *FileProvider) CodecSet(codec session.Codec) {
+	p.codec = codec
+}
+
+// Read returns raw session store by session ID, a missing session is created.
+func (p *FileProvider) Read(sid string) (session.RawStore, error) {
+	if !sidValid(sid) {
+		return nil, fmt.Errorf("session(file): invalid sid '%s'", sid)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	name := p.path(sid)
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		err = p.write(sid, nil)
+	} else if err == nil {
+		// 读取即访问, 延长会话的有效期
+		now := time.Now()
+		err = os.Chtimes(name, now, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kv := make(map[interface{}]interface{})
+	if len(data) > 0 {
+		if kv, err = p.codec.Decode(data); err != nil {
+			return nil, err
+		}
+	}
+	return &FileStore{p: p, sid: sid, data: kv}, nil
+}
+
+// Exist returns true if session with given ID exists.
+func (p *FileProvider) Exist(sid string) bool {
+	if !sidValid(sid) {
+		return false
+	}
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	_, err := os.Stat(p.path(sid))
+	return err == nil
+}
+
+// Destory deletes a session by session ID.
+func (p *FileProvider) Destory(sid string) error {
+	if !sidValid(sid) {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := os.Remove(p.path(sid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Regenerate regenerates a session store from old session ID to new one.
+func (p *FileProvider) Regenerate(oldsid, sid string) (session.RawStore, error) {
+	if !sidValid(sid) {
+		return nil, fmt.Errorf("session(file): invalid sid '%s'", sid)
+	}
+	if p.Exist(sid) {
+		return nil, fmt.Errorf("new sid '%s' already exists", sid)
+	}
+
+	p.lock.Lock()
+	err := p.rename(oldsid, sid)
+	p.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return p.Read(sid)
+}
+
+// Count counts and returns number of sessions.
+func (p *FileProvider) Count() (total int) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	p.walk(func(string, os.FileInfo) {
+		total++
+	})
+	return total
+}
+
+// GC removes session files not modified within max lifetime and their user index entries.
+func (p *FileProvider) GC() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	expired := time.Now().Add(-time.Duration(p.maxlifetime) * time.Second)
+	filepath.Walk(p.root, func(name string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && info.Name() == _INDEX_DIR {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() || !info.ModTime().Before(expired) {
+			return nil
+		}
+		if err = os.Remove(name); err != nil {
+			log.Printf("session/file: error garbage collecting: %v", err)
+		}
+		return nil
+	})
+	// 删除会话文件已不存在的索引
+	index := filepath.Join(p.root, _INDEX_DIR)
+	dirs, _ := ioutil.ReadDir(index)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, _ := ioutil.ReadDir(filepath.Join(index, dir.Name()))
+		for _, f := range files {
+			if sidValid(f.Name()) {
+				if _, err := os.Stat(p.path(f.Name())); err == nil || !os.IsNotExist(err) {
+					continue
+				}
+			}
+			if err := os.Remove(filepath.Join(index, dir.Name(), f.Name())); err != nil {
+				log.Printf("session/file: error garbage collecting index: %v", err)
+			}
+		}
+		// 目录为空时删除, 非空时失败可忽略
+		os.Remove(filepath.Join(index, dir.Name()))
+	}
+}
+
+// IndexAdd adds session ID to user ID.
+func (p *FileProvider) IndexAdd(uid, sid string) error {
+	if !sidValid(sid) {
+		return fmt.Errorf("session(file): invalid sid '%s'", sid)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	dir := p.indexPath(uid)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, sid), nil, 0600)
+}
+
+// IndexRemove removes session ID from user ID.
+func (p *FileProvider) IndexRemove(uid, sid string) error {
+	if !sidValid(sid) {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	dir := p.indexPath(uid)
+	if err := os.Remove(filepath.Join(dir, sid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// 目录为空时删除, 非空时失败可忽略
+	os.Remove(dir)
+	return nil
+}
+
+// IndexList returns session IDs of user ID.
+func (p *FileProvider) IndexList(uid string) ([]string, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	files, err := ioutil.ReadDir(p.indexPath(uid))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sids := make([]string, 0, len(files))
+	for _, f := range files {
+		if sidValid(f.Name()) {
+			sids = append(sids, f.Name())
+		}
+	}
+	return sids, nil
+}
+
+// path returns session file path, sharded by the first two characters of ID.
+func (p *FileProvider) path(sid string) string {
+	return filepath.Join(p.root, sid[0:1], sid[1:2], sid)
+}
+
+// indexPath returns index directory of user ID, hashed to be safe as a file name.
+func (p *FileProvider) indexPath(uid string) string {
+	sum := sha1.Sum([]byte(uid))
+	return filepath.Join(p.root, _INDEX_DIR, hex.EncodeToString(sum[:]))
+}
+
+// write replaces session file atomically with a temporary file and rename.
+// Caller must hold the write lock.
+func (p *FileProvider) write(sid string, data []byte) error {
+	name := p.path(sid)
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), sid+".*"+_TEMP_SUFFIX)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// rename moves session file to new ID, a missing old session becomes an empty one.
+// Caller must hold the write lock.
+func (p *FileProvider) rename(oldsid, sid string) error {
+	if sidValid(oldsid) {
+		name := p.path(sid)
+		if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			return err
+		}
+		err := os.Rename(p.path(oldsid), name)
+		if err == nil || !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return p.write(sid, nil)
+}
+
+// walk calls fn for every session file, temporary files are skipped.
+func (p *FileProvider) walk(fn func(string, os.FileInfo)) {
+	filepath.Walk(p.root, func(name string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && info.Name() == _INDEX_DIR {
+			return filepath.SkipDir
+		}
+		if err == nil && !info.IsDir() && sidValid(info.Name()) {
+			fn(name, info)
+		}
+		return nil
+	})
+}
+
+// sidValid reports whether sid is safe to use as a file name.
+func sidValid(sid string) bool {
+	if len(sid) < 2 {
+		return false
+	}
+	for i := 0; i < len(sid); i++ {
+		c := sid[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	session.Register("file", &FileProvider{})
+}
@@ -0,0 +1,93 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session/sessiontest"
+)
+
+func Test_Provider(t *testing.T) {
+	sessiontest.ProviderTest(t, &FileProvider{}, t.TempDir())
+}
+
+func Test_Layout(t *testing.T) {
+	dir := t.TempDir()
+	p := &FileProvider{}
+	if err := p.Init(60, dir); err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Read("ab12cd34")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("k", "v")
+	if err = s.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "a", "b", "ab12cd34")); err != nil {
+		t.Errorf("session file not sharded: %v", err)
+	}
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "a", "b"))
+	if len(files) != 1 {
+		t.Errorf("temporary files left: %d files", len(files))
+	}
+
+	for _, sid := range []string{"", "a", "../../etc", "ab/cd", "ab.cd"} {
+		if p.Exist(sid) {
+			t.Errorf("Exist(%q) = true", sid)
+		}
+		if _, err = p.Read(sid); err == nil {
+			t.Errorf("Read(%q) accepted invalid sid", sid)
+		}
+	}
+}
+
+func Test_CodecJSON(t *testing.T) {
+	dir := t.TempDir()
+	p := &FileProvider{}
+	p.CodecSet(session.JSONCodec{})
+	if err := p.Init(60, dir); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := p.Read("cd12ab34")
+	s.Set("user", "alice")
+	if err := s.Release(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "c", "d", "cd12ab34"))
+	if string(data) != `{"user":"alice"}` {
+		t.Errorf("file content %s", data)
+	}
+	if s, _ = p.Read("cd12ab34"); s.Get("user") != "alice" {
+		t.Errorf("read back %v", s.Get("user"))
+	}
+}
+
+func Test_GCIndex(t *testing.T) {
+	dir := t.TempDir()
+	p := &FileProvider{}
+	if err := p.Init(60, dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, sid := range []string{"aa11", "bb22"} {
+		s, _ := p.Read(sid)
+		s.Set("k", "v")
+		s.Release()
+		p.IndexAdd("alice", sid)
+	}
+	p.IndexAdd("bob", "cc33")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "a", "a", "aa11"), old, old)
+	p.GC()
+	if sids, _ := p.IndexList("alice"); len(sids) != 1 || sids[0] != "bb22" {
+		t.Errorf("alice index after GC: %v", sids)
+	}
+	if _, err := os.Stat(p.indexPath("bob")); !os.IsNotExist(err) {
+		t.Errorf("empty index dir left: %v", err)
+	}
+}
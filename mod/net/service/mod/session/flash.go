@@ -0,0 +1,190 @@
+package session
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+// ___________.____       _____    _________ ___ ___
+// \_   _____/|    |     /  _  \  /   _____//   |   \
+//  |    __)  |    |    /  /_\  \ \_____  \/    ~    \
+//  |     \   |    |___/    |    \/        \    Y    /
+//  \___  /   |_______ \____|__  /_______  /\___|_  /
+//      \/            \/       \/        \/       \/
+
+// FlashMessage is one flash message of a kind such as error or success, with optional payload.
+// Payload of messages from the previous request is decoded from JSON, so Data holds
+// maps and slices instead of the original type; use DataDecode to get the original type.
+type FlashMessage struct {
+	Kind    string
+	Message string
+	Data    interface{}
+
+	payload []byte
+}
+
+// flashEntry is FlashMessage saved in session, payload is JSON encoded so that
+// it can be decoded after restart without registering its type.
+type flashEntry struct {
+	Kind    string
+	Message string
+	Data    []byte
+}
+
+// Flash keeps messages in session store until the next request that reads them,
+// so they survive redirects and can not be read or forged by the client.
+// Messages of the current request are in Messages, Values and the *Msg fields, and the
+// template variable Flash is set when there are any.
+type Flash struct {
+	ctx *service.Context
+	url.Values
+	ErrorMsg, WarningMsg, InfoMsg, SuccessMsg string
+	Messages                                  []FlashMessage
+
+	store  *store
+	next   []FlashMessage
+	loaded []FlashMessage
+}
+
+const (
+	FLASH_ERROR   = "error"
+	FLASH_WARNING = "warning"
+	FLASH_INFO    = "info"
+	FLASH_SUCCESS = "success"
+
+	_SESSION_FLASH = "_session_flash"
+)
+
+// flashNew takes messages saved by the previous request out of session.
+func flashNew(ctx *service.Context, s *store) *Flash {
+	f := &Flash{ctx: ctx, Values: url.Values{}, store: s}
+	if s.Get(_SESSION_FLASH) == nil {
+		return f
+	}
+	var entries []flashEntry
+	if err := s.GetStruct(_SESSION_FLASH, &entries); err != nil {
+		ctx.Log.Errorln("session(flash): " + err.Error())
+	}
+	s.Delete(_SESSION_FLASH)
+	for _, e := range entries {
+		m := FlashMessage{Kind: e.Kind, Message: e.Message, payload: e.Data}
+		if len(m.payload) > 0 {
+			json.Unmarshal(m.payload, &m.Data)
+		}
+		f.loaded = append(f.loaded, m)
+		f.show(m)
+	}
+	return f
+}
+
+// Add adds a message shown in the next request, such as the one after a redirect.
+func (f *Flash) Add(kind, msg string, data ...interface{}) {
+	m := FlashMessage{Kind: kind, Message: msg}
+	if len(data) > 0 {
+		m.Data = data[0]
+		payload, err := json.Marshal(m.Data)
+		if err != nil {
+			f.ctx.Log.Errorln("session(flash): " + err.Error())
+		} else {
+			m.payload = payload
+		}
+	}
+	f.next = append(f.next, m)
+}
+
+// Now adds a message shown in the current request.
+func (f *Flash) Now(kind, msg string, data ...interface{}) {
+	m := FlashMessage{Kind: kind, Message: msg}
+	if len(data) > 0 {
+		m.Data = data[0]
+	}
+	f.show(m)
+}
+
+// Keep keeps messages read in this request for one more request.
+func (f *Flash) Keep() {
+	f.next = append(f.loaded, f.next...)
+	f.loaded = nil
+}
+
+// Kind returns messages of given kind in the current request.
+func (f *Flash) Kind(kind string) []FlashMessage {
+	var msgs []FlashMessage
+	for _, m := range f.Messages {
+		if m.Kind == kind {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// DataDecode stores payload of the message in the value pointed to by v.
+func (m FlashMessage) DataDecode(v interface{}) error {
+	data := m.payload
+	if data == nil {
+		if m.Data == nil {
+			return nil
+		}
+		var err error
+		if data, err = json.Marshal(m.Data); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (f *Flash) show(m FlashMessage) {
+	f.Messages = append(f.Messages, m)
+	f.Values.Add(m.Kind, m.Message)
+	switch m.Kind {
+	case FLASH_ERROR:
+		f.ErrorMsg = m.Message
+	case FLASH_WARNING:
+		f.WarningMsg = m.Message
+	case FLASH_INFO:
+		f.InfoMsg = m.Message
+	case FLASH_SUCCESS:
+		f.SuccessMsg = m.Message
+	}
+	f.ctx.Var["Flash"] = f
+}
+
+// save writes messages for the next request to session, it may be called more than once.
+func (f *Flash) save() {
+	if len(f.next) > 0 {
+		entries := make([]flashEntry, len(f.next))
+		for i, m := range f.next {
+			entries[i] = flashEntry{Kind: m.Kind, Message: m.Message, Data: m.payload}
+		}
+		f.store.Set(_SESSION_FLASH, entries)
+	} else if f.store.Get(_SESSION_FLASH) != nil {
+		f.store.Delete(_SESSION_FLASH)
+	}
+}
+
+// set shows message in the current request, or the next one when current is false.
+func (f *Flash) set(kind, msg string, current ...bool) {
+	if len(current) == 0 || current[0] {
+		f.Now(kind, msg)
+	} else {
+		f.Add(kind, msg)
+	}
+}
+
+func (f *Flash) Error(msg string, current ...bool) {
+	f.set(FLASH_ERROR, msg, current...)
+}
+
+func (f *Flash) Warning(msg string, current ...bool) {
+	f.set(FLASH_WARNING, msg, current...)
+}
+
+func (f *Flash) Info(msg string, current ...bool) {
+	f.set(FLASH_INFO, msg, current...)
+}
+
+func (f *Flash) Success(msg string, current ...bool) {
+	f.set(FLASH_SUCCESS, msg, current...)
+}
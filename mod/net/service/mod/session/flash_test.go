@@ -0,0 +1,75 @@
+package session_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	_ "github.com/sail-services/sail-go/mod/net/service/mod/session/cookie"
+)
+
+type order struct {
+	ID int
+}
+
+func flashServiceNew(adapter string) *service.Service {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(session.New(session.Options{Adapter: adapter, Conn: map[string]string{"cookie": "key"}[adapter]}))
+	ser.Rou.Get("/add", func(con *service.Context) {
+		f := session.DataGetFlash(con)
+		f.Add(session.FLASH_SUCCESS, "saved", order{7})
+		f.Success("second", false)
+		f.Error("now")
+		con.Ren.Redirect(302, "/show")
+	})
+	ser.Rou.Get("/show", func(con *service.Context) {
+		f := session.DataGetFlash(con)
+		var out []string
+		for _, m := range f.Kind(session.FLASH_SUCCESS) {
+			out = append(out, m.Message)
+			var o order
+			if m.Data != nil && m.DataDecode(&o) == nil {
+				out = append(out, "order", string(rune('0'+o.ID)))
+			}
+		}
+		if con.Req.FormValue("keep") != "" {
+			f.Keep()
+		}
+		con.Ren.S(200, strings.Join(out, ","))
+	})
+	return ser
+}
+
+func Test_Flash(t *testing.T) {
+	for _, adapter := range []string{"memory", "cookie"} {
+		ser := flashServiceNew(adapter)
+		var cookies []*http.Cookie
+		get := func(url string) string {
+			req := httptest.NewRequest("GET", url, nil)
+			for _, c := range cookies {
+				req.AddCookie(c)
+			}
+			rec := httptest.NewRecorder()
+			ser.Rou.ServeHTTP(rec, req)
+			if cs := (&http.Response{Header: rec.Header()}).Cookies(); len(cs) > 0 {
+				cookies = cs
+			}
+			return rec.Body.String()
+		}
+		get("/add")
+		if body := get("/show?keep=1"); body != "saved,order,7,second" {
+			t.Errorf("%s: show = %q", adapter, body)
+		}
+		if body := get("/show"); body != "saved,order,7,second" {
+			t.Errorf("%s: kept show = %q", adapter, body)
+		}
+		if body := get("/show"); body != "" {
+			t.Errorf("%s: flash shown twice: %q", adapter, body)
+		}
+	}
+}
@@ -92,10 +92,15 @@ type MemProvider struct {
 	data        map[string]*list.Element
 	// A priority list whose lastAccess newer gets higer priority.
 	list *list.List
+	// index maps user ID to its session IDs.
+	index map[string]map[string]bool
 }
 
 // Init initializes memory session provider.
 func (p *MemProvider) Init(maxLifetime int64, _ string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
 	p.maxLifetime = maxLifetime
 	return nil
 }
@@ -210,6 +215,45 @@ func (p *MemProvider) GC() {
 	p.lock.RUnlock()
 }
 
+// IndexAdd adds session ID to user ID.
+func (p *MemProvider) IndexAdd(uid, sid string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.index == nil {
+		p.index = make(map[string]map[string]bool)
+	}
+	if p.index[uid] == nil {
+		p.index[uid] = make(map[string]bool)
+	}
+	p.index[uid][sid] = true
+	return nil
+}
+
+// IndexRemove removes session ID from user ID.
+func (p *MemProvider) IndexRemove(uid, sid string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.index[uid], sid)
+	if len(p.index[uid]) == 0 {
+		delete(p.index, uid)
+	}
+	return nil
+}
+
+// IndexList returns session IDs of user ID.
+func (p *MemProvider) IndexList(uid string) ([]string, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	sids := make([]string, 0, len(p.index[uid]))
+	for sid := range p.index[uid] {
+		sids = append(sids, sid)
+	}
+	return sids, nil
+}
+
 func init() {
 	session.Register("memory", &MemProvider{list: list.New(), data: make(map[string]*list.Element)})
 }
@@ -0,0 +1,12 @@
+package memory
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/session/sessiontest"
+)
+
+func Test_Provider(t *testing.T) {
+	sessiontest.ProviderTest(t, &MemProvider{list: list.New(), data: make(map[string]*list.Element)}, "")
+}
@@ -0,0 +1,26 @@
+// Package msgpack registers the msgpack session codec, import it and set session.Options.Codec to "msgpack".
+package msgpack
+
+import (
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes session data with MessagePack, readable from other languages.
+type Codec struct{}
+
+func (Codec) Encode(kv map[interface{}]interface{}) ([]byte, error) {
+	return msgpack.Marshal(kv)
+}
+
+func (Codec) Decode(data []byte) (map[interface{}]interface{}, error) {
+	var kv map[interface{}]interface{}
+	if err := msgpack.Unmarshal(data, &kv); err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+func init() {
+	session.RegisterCodec(session.CODEC_MSGPACK, Codec{})
+}
@@ -0,0 +1,83 @@
+package msgpack_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	_ "github.com/sail-services/sail-go/mod/net/service/mod/session/file"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session/msgpack"
+)
+
+type user struct {
+	Name  string
+	Roles []string
+}
+
+// 与 session 包的 Test_Codec 相同的数据, msgpack 注册后才能加入其中, 这里单独测试
+func Test_Codec(t *testing.T) {
+	kv := map[interface{}]interface{}{
+		"name":  "alice",
+		"id":    int64(1) << 53,
+		"ratio": 0.5,
+		"admin": true,
+		"user":  user{"alice", []string{"a", "b"}},
+	}
+	data, err := msgpack.Codec{}.Encode(kv)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	out, err := msgpack.Codec{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if out["name"] != "alice" || out["admin"] != true {
+		t.Errorf("Decode = %v", out)
+	}
+}
+
+// 经 file Provider 保存后读取, 通过 GetInt64/GetStruct 等取值
+func Test_RoundTrip(t *testing.T) {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(session.New(session.Options{Adapter: "file", Conn: t.TempDir(), Codec: session.CODEC_MSGPACK}))
+	ser.Rou.Get("/set", func(con *service.Context) {
+		s := session.DataGetStore(con)
+		s.Set("name", "alice")
+		s.Set("id", int64(1)<<53)
+		s.Set("ratio", 0.5)
+		s.Set("admin", true)
+		s.Set("user", user{"alice", []string{"a", "b"}})
+		con.Ren.S(200, "ok")
+	})
+	var (
+		name  string
+		id    int64
+		ratio float64
+		admin bool
+		u     user
+		err   error
+	)
+	ser.Rou.Get("/get", func(con *service.Context) {
+		s := session.DataGetStore(con)
+		name, id, ratio, admin = s.GetString("name"), s.GetInt64("id"), s.GetFloat64("ratio"), s.GetBool("admin")
+		err = s.GetStruct("user", &u)
+		con.Ren.S(200, "ok")
+	})
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/set", nil))
+	req := httptest.NewRequest("GET", "/get", nil)
+	for _, c := range (&http.Response{Header: rec.Header()}).Cookies() {
+		req.AddCookie(c)
+	}
+	ser.Rou.ServeHTTP(httptest.NewRecorder(), req)
+	if name != "alice" || id != int64(1)<<53 || ratio != 0.5 || !admin {
+		t.Errorf("read back %q %d %v %v", name, id, ratio, admin)
+	}
+	if err != nil || u.Name != "alice" || len(u.Roles) != 2 {
+		t.Errorf("GetStruct = %+v, %v", u, err)
+	}
+}
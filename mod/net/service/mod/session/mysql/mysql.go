@@ -27,10 +27,11 @@ import (
 
 // MysqlStore represents a mysql session store implementation.
 type MysqlStore struct {
-	c    *sql.DB
-	sid  string
-	lock sync.RWMutex
-	data map[interface{}]interface{}
+	c     *sql.DB
+	sid   string
+	lock  sync.RWMutex
+	data  map[interface{}]interface{}
+	codec session.Codec
 }
 
 // NewMysqlStore creates and returns a mysql session store.
@@ -75,7 +76,7 @@ func (s *MysqlStore) ID() string {
 
 // Release releases resource and save data to provider.
 func (s *MysqlStore) Release() error {
-	data, err := session.EncodeGob(s.data)
+	data, err := s.codec.Encode(s.data)
 	if err != nil {
 		return err
 	}
@@ -98,12 +99,16 @@ func (s *MysqlStore) Flush() error {
 type MysqlProvider struct {
 	c      *sql.DB
 	expire int64
+	codec  session.Codec
 }
 
 // Init initializes mysql session provider.
 // connStr: username:password@protocol(address)/dbname?param=value
 func (p *MysqlProvider) Init(expire int64, connStr string) (err error) {
 	p.expire = expire
+	if p.codec == nil {
+		p.codec = session.GobCodec{}
+	}
 
 	p.c, err = sql.Open("mysql", connStr)
 	if err != nil {
@@ -112,6 +117,11 @@ func (p *MysqlProvider) Init(expire int64, connStr string) (err error) {
 	return p.c.Ping()
 }
 
+// CodecSet sets codec of session data.
+func (p *MysqlProvider) CodecSet(codec session.Codec) {
+	p.codec = codec
+}
+
 // Read returns raw session store by session ID.
 func (p *MysqlProvider) Read(sid string) (session.RawStore, error) {
 	var data []byte
@@ -128,13 +138,15 @@ func (p *MysqlProvider) Read(sid string) (session.RawStore, error) {
 	if len(data) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(data)
+		kv, err = p.codec.Decode(data)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return NewMysqlStore(p.c, sid, kv), nil
+	s := NewMysqlStore(p.c, sid, kv)
+	s.codec = p.codec
+	return s, nil
 }
 
 // Exist returns true if session with given ID exists.
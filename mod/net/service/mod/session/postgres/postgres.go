@@ -27,10 +27,11 @@ import (
 
 // PostgresStore represents a postgres session store implementation.
 type PostgresStore struct {
-	c    *sql.DB
-	sid  string
-	lock sync.RWMutex
-	data map[interface{}]interface{}
+	c     *sql.DB
+	sid   string
+	lock  sync.RWMutex
+	data  map[interface{}]interface{}
+	codec session.Codec
 }
 
 // NewPostgresStore creates and returns a postgres session store.
@@ -76,7 +77,7 @@ func (s *PostgresStore) ID() string {
 // save postgres session values to database.
 // must call this method to save values to database.
 func (s *PostgresStore) Release() error {
-	data, err := session.EncodeGob(s.data)
+	data, err := s.codec.Encode(s.data)
 	if err != nil {
 		return err
 	}
@@ -99,12 +100,16 @@ func (s *PostgresStore) Flush() error {
 type PostgresProvider struct {
 	c           *sql.DB
 	maxlifetime int64
+	codec       session.Codec
 }
 
 // Init initializes postgres session provider.
 // connStr: user=a password=b host=localhost port=5432 dbname=c sslmode=disable
 func (p *PostgresProvider) Init(maxlifetime int64, connStr string) (err error) {
 	p.maxlifetime = maxlifetime
+	if p.codec == nil {
+		p.codec = session.GobCodec{}
+	}
 
 	p.c, err = sql.Open("postgres", connStr)
 	if err != nil {
@@ -113,6 +118,11 @@ func (p *PostgresProvider) Init(maxlifetime int64, connStr string) (err error) {
 	return p.c.Ping()
 }
 
+// CodecSet sets codec of session data.
+func (p *PostgresProvider) CodecSet(codec session.Codec) {
+	p.codec = codec
+}
+
 // Read returns raw session store by session ID.
 func (p *PostgresProvider) Read(sid string) (session.RawStore, error) {
 	var data []byte
@@ -129,13 +139,15 @@ func (p *PostgresProvider) Read(sid string) (session.RawStore, error) {
 	if len(data) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob(data)
+		kv, err = p.codec.Decode(data)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return NewPostgresStore(p.c, sid, kv), nil
+	s := NewPostgresStore(p.c, sid, kv)
+	s.codec = p.codec
+	return s, nil
 }
 
 // Exist returns true if session with given ID exists.
@@ -34,6 +34,7 @@ type RedisStore struct {
 	duration    time.Duration
 	lock        sync.RWMutex
 	data        map[interface{}]interface{}
+	codec       session.Codec
 }
 
 // NewRedisStore creates and returns a redis session store.
@@ -80,12 +81,14 @@ func (s *RedisStore) ID() string {
 
 // Release releases resource and save data to provider.
 func (s *RedisStore) Release() error {
-	data, err := session.EncodeGob(s.data)
+	s.lock.RLock()
+	data, err := s.codec.Encode(s.data)
+	s.lock.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	return s.c.SetEx(s.prefix, s.duration, string(data)).Err()
+	return s.c.SetEx(s.prefix+s.sid, s.duration, string(data)).Err()
 }
 
 // Flush deletes all session data.
@@ -102,6 +105,7 @@ type RedisProvider struct {
 	c        *redis.Client
 	prefix   string
 	duration time.Duration
+	codec    session.Codec
 }
 
 // Conn: network=tcp,addr=127.0.0.1:6379,password=,db=0,pool_size=100,idle_timeout=180
@@ -110,6 +114,9 @@ func (p *RedisProvider) Init(maxlifetime int64, configs string) (err error) {
 	if err != nil {
 		return err
 	}
+	if p.codec == nil {
+		p.codec = session.GobCodec{}
+	}
 
 	cfg, err := ini.Load([]byte(strings.Replace(configs, ",", "\n", -1)))
 	if err != nil {
@@ -144,6 +151,11 @@ func (p *RedisProvider) Init(maxlifetime int64, configs string) (err error) {
 	return p.c.Ping().Err()
 }
 
+// CodecSet sets codec of session data.
+func (p *RedisProvider) CodecSet(codec session.Codec) {
+	p.codec = codec
+}
+
 // Read returns raw session store by session ID.
 func (p *RedisProvider) Read(sid string) (session.RawStore, error) {
 	psid := p.prefix + sid
@@ -160,12 +172,14 @@ func (p *RedisProvider) Read(sid string) (session.RawStore, error) {
 	if len(kvs) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob([]byte(kvs))
+		kv, err = p.codec.Decode([]byte(kvs))
 		if err != nil {
 			return nil, err
 		}
 	}
-	return NewRedisStore(p.c, p.prefix, sid, p.duration, kv), nil
+	s := NewRedisStore(p.c, p.prefix, sid, p.duration, kv)
+	s.codec = p.codec
+	return s, nil
 }
 
 // Exist returns true if session with given ID exists.
@@ -202,12 +216,14 @@ func (p *RedisProvider) Regenerate(oldsid, sid string) (_ session.RawStore, err
 	if len(kvs) == 0 {
 		kv = make(map[interface{}]interface{})
 	} else {
-		kv, err = session.DecodeGob([]byte(kvs))
+		kv, err = p.codec.Decode([]byte(kvs))
 		if err != nil {
 			return nil, err
 		}
 	}
-	return NewRedisStore(p.c, p.prefix, sid, p.duration, kv), nil
+	s := NewRedisStore(p.c, p.prefix, sid, p.duration, kv)
+	s.codec = p.codec
+	return s, nil
 }
 
 // Count counts and returns number of sessions.
@@ -0,0 +1,253 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+// IndexProvider is implemented by providers keeping a secondary index from user ID to session IDs,
+// it is required by Sessions and DestoryAll. The memory, file and sqlite providers implement it.
+type IndexProvider interface {
+	Provider
+	// IndexAdd adds session ID to user ID.
+	IndexAdd(uid, sid string) error
+	// IndexRemove removes session ID from user ID.
+	IndexRemove(uid, sid string) error
+	// IndexList returns session IDs of user ID, destroyed or expired sessions may be included.
+	IndexList(uid string) ([]string, error)
+}
+
+// 会话中保存的元数据
+const (
+	_SESSION_UID      = "_session_uid"
+	_SESSION_CREATED  = "_session_created"
+	_SESSION_ACCESSED = "_session_accessed"
+	_SESSION_UA       = "_session_ua"
+	_SESSION_IP       = "_session_ip"
+)
+
+var (
+	ErrIndexUnsupported = errors.New("session: provider does not support user index")
+	ErrNoStore          = errors.New("session: no session store in context")
+)
+
+// Login regenerates session ID against fixation and binds session to user ID,
+// call it after authentication or any other privilege change.
+func (m *Manager) Login(ctx *service.Context, uid string) error {
+	if uid == "" {
+		return errors.New("session: empty user ID")
+	}
+	v, ok := ctx.DataGet(_DATA_SESSION_STORE)
+	if !ok {
+		return ErrNoStore
+	}
+	cur := v.(*store)
+	if old := toString(cur.RawStore.Get(_SESSION_UID)); old != "" && old != uid {
+		if err := m.indexRemove(old, cur.RawStore.ID()); err != nil {
+			return err
+		}
+		cur.RawStore.Delete(_SESSION_UID)
+	}
+	sess, err := m.RegenerateId(ctx)
+	if err != nil {
+		return err
+	}
+	sess.Set(_SESSION_UID, uid)
+	if ip, ok := m.provider.(IndexProvider); ok {
+		return ip.IndexAdd(uid, sess.ID())
+	}
+	return nil
+}
+
+// Sessions returns IDs of all live sessions of user ID.
+func (m *Manager) Sessions(uid string) ([]string, error) {
+	ip, ok := m.provider.(IndexProvider)
+	if !ok {
+		return nil, ErrIndexUnsupported
+	}
+	sids, err := ip.IndexList(uid)
+	if err != nil {
+		return nil, err
+	}
+	live := sids[:0]
+	for _, sid := range sids {
+		if m.provider.Exist(sid) {
+			live = append(live, sid)
+		} else if err = ip.IndexRemove(uid, sid); err != nil {
+			return nil, err
+		}
+	}
+	return live, nil
+}
+
+// DestoryAll deletes all sessions of user ID, including the current one.
+func (m *Manager) DestoryAll(ctx *service.Context, uid string) error {
+	sids, err := m.Sessions(uid)
+	if err != nil {
+		return err
+	}
+	var cur *store
+	if v, ok := ctx.DataGet(_DATA_SESSION_STORE); ok {
+		cur = v.(*store)
+	}
+	ip := m.provider.(IndexProvider)
+	for _, sid := range sids {
+		if err = m.provider.Destory(sid); err != nil {
+			return err
+		}
+		if err = ip.IndexRemove(uid, sid); err != nil {
+			return err
+		}
+		if cur != nil && cur.RawStore.ID() == sid {
+			cur.destoryed = true
+			m.cookieClear(ctx)
+		}
+	}
+	return nil
+}
+
+// UserID returns user ID bound by Login, "" if not logged in.
+func (s *store) UserID() string {
+	return toString(s.RawStore.Get(_SESSION_UID))
+}
+
+// valid reports whether session is within timeouts and matches bound user agent and IP.
+// Sessions without creation time are new or created before timeouts were enabled.
+func (m *Manager) valid(ctx *service.Context, sess RawStore) bool {
+	created := toInt64(sess.Get(_SESSION_CREATED))
+	if created == 0 {
+		return true
+	}
+	now := time.Now().Unix()
+	if m.opt.AbsoluteTimeout > 0 && now-created > m.opt.AbsoluteTimeout {
+		return false
+	}
+	if m.opt.IdleTimeout > 0 && now-toInt64(sess.Get(_SESSION_ACCESSED)) > m.opt.IdleTimeout {
+		return false
+	}
+	if m.opt.BindUserAgent {
+		if ua := toString(sess.Get(_SESSION_UA)); ua != "" && ua != uaHash(ctx.Req.UserAgent()) {
+			return false
+		}
+	}
+	if m.opt.BindIPv4 > 0 || m.opt.BindIPv6 > 0 {
+		if ip := toString(sess.Get(_SESSION_IP)); ip != "" && ip != m.ipPrefix(m.opt.ClientIPFunc(ctx.Req.Request)) {
+			return false
+		}
+	}
+	return true
+}
+
+// touch records creation time and bindings of new session, and access time for idle timeout.
+// It returns the first error of the store, e.g. a cookie session exceeding its size limit.
+func (m *Manager) touch(ctx *service.Context, sess RawStore) (err error) {
+	set := func(key, val interface{}) {
+		if e := sess.Set(key, val); e != nil && err == nil {
+			err = e
+		}
+	}
+	now := time.Now().Unix()
+	if sess.Get(_SESSION_CREATED) == nil {
+		set(_SESSION_CREATED, now)
+		set(_SESSION_ACCESSED, now)
+		if m.opt.BindUserAgent {
+			set(_SESSION_UA, uaHash(ctx.Req.UserAgent()))
+		}
+		if m.opt.BindIPv4 > 0 || m.opt.BindIPv6 > 0 {
+			set(_SESSION_IP, m.ipPrefix(m.opt.ClientIPFunc(ctx.Req.Request)))
+		}
+		return
+	}
+	if m.opt.IdleTimeout == 0 {
+		return
+	}
+	// 访问时间按步长更新, 避免 Cookie 会话每个请求都重写
+	step := m.opt.IdleTimeout / 10
+	if step > 60 {
+		step = 60
+	}
+	if now-toInt64(sess.Get(_SESSION_ACCESSED)) >= step {
+		set(_SESSION_ACCESSED, now)
+	}
+	return
+}
+
+// destory deletes session and its user index entry.
+func (m *Manager) destory(sess RawStore) error {
+	if err := m.provider.Destory(sess.ID()); err != nil {
+		return err
+	}
+	return m.indexRemove(toString(sess.Get(_SESSION_UID)), sess.ID())
+}
+
+func (m *Manager) indexRemove(uid, sid string) error {
+	if ip, ok := m.provider.(IndexProvider); ok && uid != "" {
+		return ip.IndexRemove(uid, sid)
+	}
+	return nil
+}
+
+func (m *Manager) indexMove(uid, oldsid, sid string) error {
+	ip, ok := m.provider.(IndexProvider)
+	if !ok {
+		return nil
+	}
+	if err := ip.IndexRemove(uid, oldsid); err != nil {
+		return err
+	}
+	return ip.IndexAdd(uid, sid)
+}
+
+func (m *Manager) cookieClear(ctx *service.Context) {
+	cookieSet(ctx, &http.Cookie{
+		Name:     m.opt.CookieName,
+		Path:     m.opt.CookiePath,
+		HttpOnly: true,
+		Expires:  time.Now(),
+		MaxAge:   -1,
+	})
+}
+
+// cookieSet sets session cookie, replacing the one set earlier in the same response.
+func cookieSet(ctx *service.Context, ck *http.Cookie) {
+	hd := ctx.Resp.Header()
+	prefix := ck.Name + "="
+	kept := hd["Set-Cookie"][:0]
+	for _, v := range hd["Set-Cookie"] {
+		if !strings.HasPrefix(v, prefix) {
+			kept = append(kept, v)
+		}
+	}
+	hd["Set-Cookie"] = kept
+	http.SetCookie(ctx.Resp, ck)
+}
+
+// ipPrefix masks IP with prefix length of Options.BindIPv4 or BindIPv6, "" if not bound.
+func (m *Manager) ipPrefix(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		if m.opt.BindIPv4 == 0 {
+			return ""
+		}
+		return ip4.Mask(net.CIDRMask(m.opt.BindIPv4, 32)).String()
+	}
+	if m.opt.BindIPv6 == 0 {
+		return ""
+	}
+	return ip.Mask(net.CIDRMask(m.opt.BindIPv6, 128)).String()
+}
+
+func uaHash(ua string) string {
+	sum := sha256.Sum256([]byte(ua))
+	return hex.EncodeToString(sum[:8])
+}
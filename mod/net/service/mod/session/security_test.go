@@ -0,0 +1,168 @@
+package session_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	_ "github.com/sail-services/sail-go/mod/net/service/mod/session/memory"
+)
+
+func serviceNew(opt session.Options) *service.Service {
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(session.New(opt))
+	ser.Rou.Get("/set", func(con *service.Context) {
+		session.DataGetStore(con).Set("v", con.Req.FormValue("v"))
+		con.Ren.S(200, "ok")
+	})
+	ser.Rou.Get("/get", func(con *service.Context) {
+		con.Ren.S(200, session.DataGetStore(con).GetString("v"))
+	})
+	ser.Rou.Get("/login", func(con *service.Context) {
+		if err := session.DataGetStore(con).Login(con, con.Req.FormValue("uid")); err != nil {
+			con.Ren.S(500, err.Error())
+			return
+		}
+		con.Ren.S(200, session.DataGetStore(con).UserID())
+	})
+	ser.Rou.Get("/logout-all", func(con *service.Context) {
+		s := session.DataGetStore(con)
+		if err := s.DestoryAll(con, s.UserID()); err != nil {
+			con.Ren.S(500, err.Error())
+			return
+		}
+		con.Ren.S(200, "bye")
+	})
+	return ser
+}
+
+func do(ser *service.Service, url string, c *http.Cookie, ua string) (*httptest.ResponseRecorder, *http.Cookie) {
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set("User-Agent", ua)
+	if c != nil {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	for _, ck := range (&http.Response{Header: rec.Header()}).Cookies() {
+		if ck.Name == "SESSION" {
+			if ck.MaxAge < 0 {
+				return rec, nil
+			}
+			return rec, ck
+		}
+	}
+	return rec, c
+}
+
+func Test_Login(t *testing.T) {
+	ser := serviceNew(session.Options{})
+	_, c := do(ser, "/set?v=cart", nil, "")
+	old := c.Value
+	rec, c := do(ser, "/login?uid=alice", c, "")
+	if rec.Body.String() != "alice" || c.Value == old {
+		t.Fatalf("login: %q, cookie %v", rec.Body.String(), c)
+	}
+	if rec, _ = do(ser, "/get", c, ""); rec.Body.String() != "cart" {
+		t.Errorf("data lost on login: %q", rec.Body.String())
+	}
+	if rec, _ = do(ser, "/get", &http.Cookie{Name: "SESSION", Value: old}, ""); rec.Body.String() != "" {
+		t.Errorf("old session ID still valid")
+	}
+}
+
+func Test_DestoryAll(t *testing.T) {
+	ser := serviceNew(session.Options{})
+	_, c1 := do(ser, "/login?uid=bob", nil, "")
+	_, c2 := do(ser, "/login?uid=bob", nil, "")
+	do(ser, "/set?v=phone", c2, "")
+	rec, c := do(ser, "/logout-all", c1, "")
+	if rec.Body.String() != "bye" || c != nil {
+		t.Fatalf("logout all: %q, cookie %v", rec.Body.String(), c)
+	}
+	if rec, _ = do(ser, "/get", c2, ""); rec.Body.String() != "" {
+		t.Errorf("other session survived: %q", rec.Body.String())
+	}
+}
+
+func Test_BindUserAgent(t *testing.T) {
+	ser := serviceNew(session.Options{BindUserAgent: true})
+	_, c := do(ser, "/set?v=x", nil, "firefox")
+	if rec, _ := do(ser, "/get", c, "firefox"); rec.Body.String() != "x" {
+		t.Errorf("same agent: %q", rec.Body.String())
+	}
+	rec, c2 := do(ser, "/get", c, "curl")
+	if rec.Body.String() != "" || c2.Value == c.Value {
+		t.Errorf("other agent reused session: %q", rec.Body.String())
+	}
+}
+
+func Test_IdleTimeout(t *testing.T) {
+	ser := serviceNew(session.Options{IdleTimeout: 1})
+	_, c := do(ser, "/set?v=x", nil, "")
+	time.Sleep(2100 * time.Millisecond)
+	if rec, _ := do(ser, "/get", c, ""); rec.Body.String() != "" {
+		t.Errorf("idle session still valid: %q", rec.Body.String())
+	}
+}
+
+func Test_AbsoluteTimeout(t *testing.T) {
+	ser := serviceNew(session.Options{AbsoluteTimeout: 1})
+	_, c := do(ser, "/set?v=x", nil, "")
+	time.Sleep(500 * time.Millisecond)
+	if rec, _ := do(ser, "/get", c, ""); rec.Body.String() != "x" {
+		t.Fatalf("session expired early: %q", rec.Body.String())
+	}
+	// 访问过也不延长绝对超时
+	time.Sleep(1700 * time.Millisecond)
+	if rec, _ := do(ser, "/get", c, ""); rec.Body.String() != "" {
+		t.Errorf("session valid after absolute timeout: %q", rec.Body.String())
+	}
+}
+
+// doFrom 以指定的连接地址与 X-Forwarded-For 发出请求
+func doFrom(ser *service.Service, url string, c *http.Cookie, remote, forwarded string) string {
+	req := httptest.NewRequest("GET", url, nil)
+	req.RemoteAddr = remote
+	if forwarded != "" {
+		req.Header.Set("X-Forwarded-For", forwarded)
+	}
+	if c != nil {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	return rec.Body.String()
+}
+
+func Test_BindIP(t *testing.T) {
+	for _, tc := range []struct {
+		opt                   session.Options
+		owner, near, far, xff string
+	}{
+		{session.Options{BindIPv4: 24}, "10.0.1.5:1000", "10.0.1.9:2000", "10.0.2.5:1000", "10.0.1.5"},
+		{session.Options{BindIPv6: 64}, "[2001:db8:0:1::5]:1000", "[2001:db8:0:1::9]:2000", "[2001:db8:0:2::5]:1000", "2001:db8:0:1::5"},
+	} {
+		ser := serviceNew(tc.opt)
+		req := httptest.NewRequest("GET", "/set?v=x", nil)
+		req.RemoteAddr = tc.owner
+		rec := httptest.NewRecorder()
+		ser.Rou.ServeHTTP(rec, req)
+		c := (&http.Response{Header: rec.Header()}).Cookies()[0]
+		if v := doFrom(ser, "/get", c, tc.near, ""); v != "x" {
+			t.Errorf("%s: same prefix rejected: %q", tc.near, v)
+		}
+		if v := doFrom(ser, "/get", c, tc.far, ""); v != "" {
+			t.Errorf("%s: other prefix accepted: %q", tc.far, v)
+		}
+		// X-Forwarded-For 可由客户端伪造, 不参与绑定
+		if v := doFrom(ser, "/get", c, tc.far, tc.xff); v != "" {
+			t.Errorf("%s: spoofed X-Forwarded-For accepted: %q", tc.far, v)
+		}
+	}
+}
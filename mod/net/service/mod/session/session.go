@@ -3,8 +3,8 @@ package session
 import (
 	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
-	"net/url"
 	"time"
 
 	"github.com/sail-services/sail-go/mod/net/service"
@@ -39,11 +39,36 @@ type Store interface {
 	Count() int
 	// GC calls GC to clean expired sessions.
 	GC()
+
+	// GetString returns value of given key as string, "" if missing.
+	GetString(interface{}) string
+	// GetInt returns value of given key as int, 0 if missing or not a number.
+	GetInt(interface{}) int
+	// GetInt64 returns value of given key as int64, 0 if missing or not a number.
+	GetInt64(interface{}) int64
+	// GetFloat64 returns value of given key as float64, 0 if missing or not a number.
+	GetFloat64(interface{}) float64
+	// GetBool returns value of given key as bool, false if missing.
+	GetBool(interface{}) bool
+	// GetStruct stores value of given key in the value pointed to by v.
+	GetStruct(key interface{}, v interface{}) error
+	// Pop returns value of given key and deletes it from session.
+	Pop(interface{}) interface{}
+
+	// Login regenerates session ID against fixation and binds session to user ID.
+	Login(*service.Context, string) error
+	// UserID returns user ID bound by Login, "" if not logged in.
+	UserID() string
+	// Sessions returns IDs of all live sessions of user ID.
+	Sessions(string) ([]string, error)
+	// DestoryAll deletes all sessions of user ID, the log out everywhere.
+	DestoryAll(*service.Context, string) error
 }
 
 type store struct {
 	RawStore
 	*Manager
+	destoryed bool
 }
 
 const (
@@ -65,6 +90,15 @@ type Options struct {
 	Domain         string
 	IDLength       int
 	Section        string
+	Codec          string // 序列化方式 gob / json / msgpack, 只对保存字节的 Provider 有效 [gob]
+
+	IdleTimeout     int64 // 空闲超时秒数, 超过后会话失效, 0 为只依赖 Maxlifetime [0]
+	AbsoluteTimeout int64 // 从创建起的绝对超时秒数, 0 为不限制 [0]
+	BindUserAgent   bool  // 会话绑定 User-Agent, 不一致时失效 [false]
+	BindIPv4        int   // 会话绑定的 IPv4 前缀长度, 如 24, 0 为不绑定 [0]
+	BindIPv6        int   // 会话绑定的 IPv6 前缀长度, 如 64, 0 为不绑定 [0]
+
+	ClientIPFunc func(*http.Request) string // 绑定 IP 时取客户端地址, 位于可信代理后时设置 [RemoteAddr]
 }
 
 func prepareOptions(options []Options) Options {
@@ -75,9 +109,6 @@ func prepareOptions(options []Options) Options {
 	if opt.Adapter == "" {
 		opt.Adapter = "memory"
 	}
-	if opt.Conn == "" {
-		opt.Conn = "session"
-	}
 	if opt.CookieName == "" {
 		opt.CookieName = "SESSION"
 	}
@@ -102,6 +133,25 @@ func prepareOptions(options []Options) Options {
 	if opt.IDLength == 0 {
 		opt.IDLength = 16
 	}
+	if opt.Codec == "" {
+		opt.Codec = CODEC_GOB
+	}
+	if opt.IdleTimeout < 0 || opt.AbsoluteTimeout < 0 {
+		panic("session: timeout must not be negative")
+	}
+	if opt.BindIPv4 < 0 || opt.BindIPv4 > 32 || opt.BindIPv6 < 0 || opt.BindIPv6 > 128 {
+		panic("session: invalid IP prefix length")
+	}
+	if opt.ClientIPFunc == nil {
+		// 不使用 X-Real-IP/X-Forwarded-For, 客户端可任意设置
+		opt.ClientIPFunc = func(req *http.Request) string {
+			host, _, err := net.SplitHostPort(req.RemoteAddr)
+			if err != nil {
+				return req.RemoteAddr
+			}
+			return host
+		}
+	}
 
 	return opt
 }
@@ -110,8 +160,13 @@ func DataGetStore(con *service.Context) Store {
 	return con.DataMustGet(_DATA_SESSION_STORE).(Store)
 }
 
-func DataGetFlash(con *service.Context) Flash {
-	return con.DataMustGet(_DATA_SESSION_FLASH).(Flash)
+func DataHasStore(con *service.Context) bool {
+	_, ok := con.DataGet(_DATA_SESSION_STORE)
+	return ok
+}
+
+func DataGetFlash(con *service.Context) *Flash {
+	return con.DataMustGet(_DATA_SESSION_FLASH).(*Flash)
 }
 
 func New(options ...Options) service.Handler {
@@ -126,30 +181,26 @@ func New(options ...Options) service.Handler {
 		if err != nil {
 			panic("session(start): " + err.Error())
 		}
-		vals, _ := url.ParseQuery(ctx.Req.CookieGet("session_flash"))
-		if len(vals) > 0 {
-			f := &Flash{Values: vals}
-			f.ErrorMsg = f.Get("error")
-			f.SuccessMsg = f.Get("success")
-			f.InfoMsg = f.Get("info")
-			f.WarningMsg = f.Get("warning")
-			ctx.Var["Flash"] = f
-			ctx.Resp.CookieSet("session_flash", "", -1, opt.CookiePath)
-		}
-		f := &Flash{ctx, url.Values{}, "", "", "", ""}
-		ctx.Resp.Before(func(service.Response) {
-			if flash := f.Encode(); len(flash) > 0 {
-				ctx.Resp.CookieSet("session_flash", flash, 0, opt.CookiePath)
-			}
-		})
-		ctx.DataSet(_DATA_SESSION_FLASH, f)
 		s := &store{
 			RawStore: sess,
 			Manager:  manager,
 		}
 		ctx.DataSet(_DATA_SESSION_STORE, s)
+		f := flashNew(ctx, s)
+		ctx.DataSet(_DATA_SESSION_FLASH, f)
+		// 旧版本保存在 Cookie 中的 Flash 不再读取
+		if ctx.Req.CookieGet("session_flash") != "" {
+			ctx.Resp.CookieSet("session_flash", "", -1, opt.CookiePath)
+		}
+		ctx.Resp.Before(func(service.Response) {
+			f.save()
+		})
 		ctx.Next()
-		if err = sess.Release(); err != nil {
+		if s.destoryed {
+			return
+		}
+		f.save()
+		if err = s.RawStore.Release(); err != nil {
 			panic("session(release): " + err.Error())
 		}
 	}
@@ -173,6 +224,14 @@ type Provider interface {
 	GC()
 }
 
+// CookieProvider 由把数据保存在 Cookie 中的 Provider 实现, Read 的参数为 Cookie 的值,
+// 读取失败时返回新的空会话. 返回的 RawStore 需实现 IDSet(string) 以支持 RegenerateId
+type CookieProvider interface {
+	Provider
+	// Encode 返回写入 Cookie 的值, 数据未修改时 changed 为 false, 数据为空时 value 为空
+	Encode(sess RawStore) (value string, changed bool, err error)
+}
+
 var providers = make(map[string]Provider)
 
 // Register registers a provider.
@@ -206,6 +265,13 @@ func NewManager(name string, opt Options) (*Manager, error) {
 	if !ok {
 		return nil, fmt.Errorf("session: unknown provider '%s'(forgotten import?)", name)
 	}
+	if cp, ok := p.(CodecProvider); ok {
+		codec, ok := codecs[opt.Codec]
+		if !ok {
+			return nil, fmt.Errorf("session: unknown codec '%s'(forgotten import?)", opt.Codec)
+		}
+		cp.CodecSet(codec)
+	}
 	return &Manager{p, opt}, p.Init(opt.Maxlifetime, opt.Conn)
 }
 
@@ -216,10 +282,24 @@ func (m *Manager) sessionId() string {
 
 // Start starts a session by generating new one
 // or retrieve existence one by reading session ID from HTTP request if it's valid.
+// Sessions past idle or absolute timeout, or not matching bound user agent and IP, are destroyed.
 func (m *Manager) Start(ctx *service.Context) (RawStore, error) {
+	if cp, ok := m.provider.(CookieProvider); ok {
+		return m.cookieStart(ctx, cp)
+	}
 	sid := ctx.Req.CookieGet(m.opt.CookieName)
 	if len(sid) > 0 && m.provider.Exist(sid) {
-		return m.provider.Read(sid)
+		sess, err := m.provider.Read(sid)
+		if err != nil {
+			return nil, err
+		}
+		if m.valid(ctx, sess) {
+			m.touch(ctx, sess)
+			return sess, nil
+		}
+		if err = m.destory(sess); err != nil {
+			return nil, err
+		}
 	}
 
 	sid = m.sessionId()
@@ -227,6 +307,7 @@ func (m *Manager) Start(ctx *service.Context) (RawStore, error) {
 	if err != nil {
 		return nil, err
 	}
+	m.touch(ctx, sess)
 
 	cookie := &http.Cookie{
 		Name:     m.opt.CookieName,
@@ -244,6 +325,62 @@ func (m *Manager) Start(ctx *service.Context) (RawStore, error) {
 	return sess, nil
 }
 
+// cookieStart reads session data from cookie and writes it back before the response header is sent.
+func (m *Manager) cookieStart(ctx *service.Context, cp CookieProvider) (RawStore, error) {
+	had := ctx.Req.CookieGet(m.opt.CookieName) != ""
+	sess, err := cp.Read(ctx.Req.CookieGet(m.opt.CookieName))
+	if err != nil {
+		return nil, err
+	}
+	if m.valid(ctx, sess) {
+		// 新会话在有数据写入 Cookie 时才记录创建时间, 避免为每个访客写 Cookie
+		if sess.Get(_SESSION_CREATED) != nil {
+			m.touch(ctx, sess)
+		}
+	} else {
+		if sess, err = cp.Read(""); err != nil {
+			return nil, err
+		}
+		sess.Flush()
+	}
+	ctx.Resp.Before(func(resp service.Response) {
+		if s, ok := ctx.DataGet(_DATA_SESSION_STORE); ok {
+			sess = s.(*store).RawStore
+		}
+		value, changed, err := cp.Encode(sess)
+		if err == nil && changed && value != "" && sess.Get(_SESSION_CREATED) == nil {
+			if err = m.touch(ctx, sess); err == nil {
+				value, changed, err = cp.Encode(sess)
+			}
+		}
+		// 会话无法写入 Cookie 时数据会丢失, 返回 500
+		if err != nil {
+			ctx.Log.Errorln("session(cookie): " + err.Error())
+			resp.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !changed || (value == "" && !had) {
+			return
+		}
+		cookie := &http.Cookie{
+			Name:     m.opt.CookieName,
+			Value:    value,
+			Path:     m.opt.CookiePath,
+			HttpOnly: true,
+			Secure:   m.opt.Secure,
+			Domain:   m.opt.Domain,
+			SameSite: http.SameSiteLaxMode,
+		}
+		if value == "" {
+			cookie.MaxAge = -1
+		} else if m.opt.CookieLifeTime >= 0 {
+			cookie.MaxAge = m.opt.CookieLifeTime
+		}
+		http.SetCookie(ctx.Resp, cookie)
+	})
+	return sess, nil
+}
+
 // Read returns raw session store by session ID.
 func (m *Manager) Read(sid string) (RawStore, error) {
 	return m.provider.Read(sid)
@@ -251,33 +388,63 @@ func (m *Manager) Read(sid string) (RawStore, error) {
 
 // Destory deletes a session by given ID.
 func (m *Manager) Destory(ctx *service.Context) error {
-	sid := ctx.Req.CookieGet(m.opt.CookieName)
-	if sid == "" {
+	if _, ok := m.provider.(CookieProvider); ok {
+		if s, ok := ctx.DataGet(_DATA_SESSION_STORE); ok {
+			return s.(*store).RawStore.Flush()
+		}
 		return nil
 	}
-
-	if err := m.provider.Destory(sid); err != nil {
-		return err
-	}
-	cookie := &http.Cookie{
-		Name:     m.opt.CookieName,
-		Path:     m.opt.CookiePath,
-		HttpOnly: true,
-		Expires:  time.Now(),
-		MaxAge:   -1,
+	if s, ok := ctx.DataGet(_DATA_SESSION_STORE); ok {
+		s.(*store).destoryed = true
+		if err := m.destory(s.(*store).RawStore); err != nil {
+			return err
+		}
+	} else if sid := ctx.Req.CookieGet(m.opt.CookieName); sid != "" {
+		if err := m.provider.Destory(sid); err != nil {
+			return err
+		}
+	} else {
+		return nil
 	}
-	http.SetCookie(ctx.Resp, cookie)
+	m.cookieClear(ctx)
 	return nil
 }
 
-// RegenerateId regenerates a session store from old session ID to new one.
+// RegenerateId regenerates a session store from old session ID to new one,
+// the session in context is replaced by the regenerated one.
 func (m *Manager) RegenerateId(ctx *service.Context) (sess RawStore, err error) {
 	sid := m.sessionId()
+	cur, _ := ctx.DataGet(_DATA_SESSION_STORE)
+	if _, ok := m.provider.(CookieProvider); ok {
+		if cur == nil {
+			return nil, ErrNoStore
+		}
+		sess = cur.(*store).RawStore
+		sess.(interface {
+			IDSet(string)
+		}).IDSet(sid)
+		return sess, nil
+	}
 	oldsid := ctx.Req.CookieGet(m.opt.CookieName)
+	if cur != nil {
+		// 先保存本次请求的修改, Provider 从已保存的数据生成新会话
+		if err = cur.(*store).RawStore.Release(); err != nil {
+			return nil, err
+		}
+		oldsid = cur.(*store).RawStore.ID()
+	}
 	sess, err = m.provider.Regenerate(oldsid, sid)
 	if err != nil {
 		return nil, err
 	}
+	if uid := toString(sess.Get(_SESSION_UID)); uid != "" {
+		if err = m.indexMove(uid, oldsid, sid); err != nil {
+			return nil, err
+		}
+	}
+	if cur != nil {
+		cur.(*store).RawStore = sess
+	}
 	ck := &http.Cookie{
 		Name:     m.opt.CookieName,
 		Value:    sid,
@@ -289,7 +456,7 @@ func (m *Manager) RegenerateId(ctx *service.Context) (sess RawStore, err error)
 	if m.opt.CookieLifeTime >= 0 {
 		ck.MaxAge = m.opt.CookieLifeTime
 	}
-	http.SetCookie(ctx.Resp, ck)
+	cookieSet(ctx, ck)
 	ctx.Req.AddCookie(ck)
 	return sess, nil
 }
@@ -314,48 +481,3 @@ func (m *Manager) startGC() {
 func (m *Manager) SetSecure(secure bool) {
 	m.opt.Secure = secure
 }
-
-// ___________.____       _____    _________ ___ ___
-// \_   _____/|    |     /  _  \  /   _____//   |   \
-//  |    __)  |    |    /  /_\  \ \_____  \/    ~    \
-//  |     \   |    |___/    |    \/        \    Y    /
-//  \___  /   |_______ \____|__  /_______  /\___|_  /
-//      \/            \/       \/        \/       \/
-
-type Flash struct {
-	ctx *service.Context
-	url.Values
-	ErrorMsg, WarningMsg, InfoMsg, SuccessMsg string
-}
-
-func (f *Flash) set(name, msg string, current ...bool) {
-	isShow := false
-	if (len(current) == 0) || (len(current) > 0 && current[0]) {
-		isShow = true
-	}
-	if isShow {
-		f.ctx.Var["Flash"] = f
-	} else {
-		f.Set(name, msg)
-	}
-}
-
-func (f *Flash) Error(msg string, current ...bool) {
-	f.ErrorMsg = msg
-	f.set("error", msg, current...)
-}
-
-func (f *Flash) Warning(msg string, current ...bool) {
-	f.WarningMsg = msg
-	f.set("warning", msg, current...)
-}
-
-func (f *Flash) Info(msg string, current ...bool) {
-	f.InfoMsg = msg
-	f.set("info", msg, current...)
-}
-
-func (f *Flash) Success(msg string, current ...bool) {
-	f.SuccessMsg = msg
-	f.set("success", msg, current...)
-}
@@ -0,0 +1,239 @@
+// Package sessiontest provides the conformance suite every session.Provider adapter runs in its tests.
+package sessiontest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+)
+
+// ProviderTest checks that a fresh, uninitialized provider behaves like the in-memory one:
+// Read creates, Release persists, Regenerate moves, Destory removes and GC expires sessions.
+// Providers implementing session.IndexProvider also have their user index checked.
+// The provider is initialized with a max lifetime of 1 second and the given config.
+func ProviderTest(t *testing.T, p session.Provider, config string) {
+	if err := p.Init(1, config); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Run("ReadWrite", func(t *testing.T) { readWrite(t, p) })
+	t.Run("Regenerate", func(t *testing.T) { regenerate(t, p) })
+	t.Run("Destory", func(t *testing.T) { destory(t, p) })
+	t.Run("Count", func(t *testing.T) { count(t, p) })
+	t.Run("Concurrent", func(t *testing.T) { concurrent(t, p) })
+	if ip, ok := p.(session.IndexProvider); ok {
+		t.Run("Index", func(t *testing.T) { index(t, ip) })
+	}
+	t.Run("GC", func(t *testing.T) { gc(t, p) })
+}
+
+var seq int
+
+// sid returns a session ID unique within the test binary.
+func sid() string {
+	seq++
+	return fmt.Sprintf("%08x%08x", time.Now().UnixNano()&0xffffffff, seq)
+}
+
+func read(t *testing.T, p session.Provider, id string) session.RawStore {
+	t.Helper()
+	s, err := p.Read(id)
+	if err != nil {
+		t.Fatalf("Read(%s): %v", id, err)
+	}
+	if s.ID() != id {
+		t.Fatalf("Read(%s) returned ID %s", id, s.ID())
+	}
+	return s
+}
+
+func readWrite(t *testing.T, p session.Provider) {
+	id := sid()
+	if p.Exist(id) {
+		t.Fatalf("Exist(%s) before Read", id)
+	}
+	s := read(t, p, id)
+	if !p.Exist(id) {
+		t.Errorf("Exist(%s) false after Read", id)
+	}
+	if v := s.Get("name"); v != nil {
+		t.Errorf("new session has value %v", v)
+	}
+	s.Set("name", "alice")
+	s.Set("age", 30)
+	s.Set("gone", true)
+	if err := s.Delete("gone"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := s.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	s = read(t, p, id)
+	if v := s.Get("name"); v != "alice" {
+		t.Errorf("name = %v", v)
+	}
+	if v := s.Get("age"); v != 30 {
+		t.Errorf("age = %v", v)
+	}
+	if v := s.Get("gone"); v != nil {
+		t.Errorf("deleted key = %v", v)
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+	if err := s.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if v := read(t, p, id).Get("name"); v != nil {
+		t.Errorf("value %v after Flush", v)
+	}
+}
+
+func regenerate(t *testing.T, p session.Provider) {
+	old, id := sid(), sid()
+	s := read(t, p, old)
+	s.Set("name", "bob")
+	if err := s.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	s, err := p.Regenerate(old, id)
+	if err != nil {
+		t.Fatalf("Regenerate: %v", err)
+	}
+	if s.ID() != id {
+		t.Errorf("Regenerate returned ID %s, want %s", s.ID(), id)
+	}
+	if v := s.Get("name"); v != "bob" {
+		t.Errorf("name = %v after Regenerate", v)
+	}
+	if p.Exist(old) {
+		t.Errorf("old ID still exists")
+	}
+	if !p.Exist(id) {
+		t.Errorf("new ID does not exist")
+	}
+
+	if _, err = p.Regenerate(sid(), id); err == nil {
+		t.Errorf("Regenerate to existing ID succeeded")
+	}
+
+	// 旧 ID 不存在时创建空会话
+	missing := sid()
+	if s, err = p.Regenerate(sid(), missing); err != nil {
+		t.Fatalf("Regenerate from missing ID: %v", err)
+	}
+	if s.ID() != missing || !p.Exist(missing) {
+		t.Errorf("Regenerate from missing ID did not create %s", missing)
+	}
+}
+
+func destory(t *testing.T, p session.Provider) {
+	id := sid()
+	read(t, p, id).Release()
+	if err := p.Destory(id); err != nil {
+		t.Fatalf("Destory: %v", err)
+	}
+	if p.Exist(id) {
+		t.Errorf("Exist(%s) after Destory", id)
+	}
+	if err := p.Destory(sid()); err != nil {
+		t.Errorf("Destory of missing ID: %v", err)
+	}
+}
+
+func count(t *testing.T, p session.Provider) {
+	before := p.Count()
+	a, b := sid(), sid()
+	read(t, p, a).Release()
+	read(t, p, b).Release()
+	if n := p.Count(); n != before+2 {
+		t.Errorf("Count = %d, want %d", n, before+2)
+	}
+	p.Destory(a)
+	if n := p.Count(); n != before+1 {
+		t.Errorf("Count = %d after Destory, want %d", n, before+1)
+	}
+}
+
+func concurrent(t *testing.T, p session.Provider) {
+	id := sid()
+	read(t, p, id).Release()
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := p.Read(id)
+			if err != nil {
+				errs <- err
+				return
+			}
+			s.Set(i, i)
+			if err = s.Release(); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent access: %v", err)
+	}
+	if !p.Exist(id) {
+		t.Errorf("session lost after concurrent access")
+	}
+}
+
+func index(t *testing.T, p session.IndexProvider) {
+	uid, a, b := "user:"+sid(), sid(), sid()
+	read(t, p, a).Release()
+	read(t, p, b).Release()
+	for _, id := range []string{a, b, a} {
+		if err := p.IndexAdd(uid, id); err != nil {
+			t.Fatalf("IndexAdd: %v", err)
+		}
+	}
+	sids, err := p.IndexList(uid)
+	if err != nil {
+		t.Fatalf("IndexList: %v", err)
+	}
+	sort.Strings(sids)
+	want := []string{a, b}
+	sort.Strings(want)
+	if fmt.Sprint(sids) != fmt.Sprint(want) {
+		t.Errorf("IndexList = %v, want %v", sids, want)
+	}
+	if err = p.IndexRemove(uid, a); err != nil {
+		t.Fatalf("IndexRemove: %v", err)
+	}
+	if err = p.IndexRemove(uid, sid()); err != nil {
+		t.Errorf("IndexRemove of missing ID: %v", err)
+	}
+	if sids, _ = p.IndexList(uid); len(sids) != 1 || sids[0] != b {
+		t.Errorf("IndexList after remove = %v", sids)
+	}
+	if sids, _ = p.IndexList("nobody"); len(sids) != 0 {
+		t.Errorf("IndexList of unknown user = %v", sids)
+	}
+}
+
+func gc(t *testing.T, p session.Provider) {
+	id := sid()
+	read(t, p, id).Release()
+	p.GC()
+	if !p.Exist(id) {
+		t.Fatalf("GC removed a live session")
+	}
+	time.Sleep(2100 * time.Millisecond)
+	p.GC()
+	if p.Exist(id) {
+		t.Errorf("GC kept an expired session")
+	}
+}
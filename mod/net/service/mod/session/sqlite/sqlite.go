@@ -0,0 +1,244 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+)
+
+// SQLiteStore represents a sqlite session store implementation.
+type SQLiteStore struct {
+	c     *sql.DB
+	sid   string
+	lock  sync.RWMutex
+	data  map[interface{}]interface{}
+	codec session.Codec
+}
+
+// NewSQLiteStore creates and returns a sqlite session store.
+func NewSQLiteStore(c *sql.DB, sid string, kv map[interface{}]interface{}) *SQLiteStore {
+	return &SQLiteStore{
+		c:    c,
+		sid:  sid,
+		data: kv,
+	}
+}
+
+// Set sets value to given key in session.
+func (s *SQLiteStore) Set(key, value interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data[key] = value
+	return nil
+}
+
+// Get gets value by given key in session.
+func (s *SQLiteStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+// Delete delete a key from session.
+func (s *SQLiteStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+// ID returns current session ID.
+func (s *SQLiteStore) ID() string {
+	return s.sid
+}
+
+// save sqlite session values to database.
+// must call this method to save values to database.
+func (s *SQLiteStore) Release() error {
+	s.lock.RLock()
+	data, err := s.codec.Encode(s.data)
+	s.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.c.Exec("UPDATE session SET data=?, expiry=? WHERE key=?",
+		data, time.Now().Unix(), s.sid)
+	return err
+}
+
+// Flush deletes all session data.
+func (s *SQLiteStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	return nil
+}
+
+// SQLiteProvider represents a sqlite session provider implementation.
+type SQLiteProvider struct {
+	c           *sql.DB
+	maxlifetime int64
+	codec       session.Codec
+}
+
+// Init initializes sqlite session provider, the session table is created if missing.
+// connStr: file:data/session.db?_busy_timeout=5000, default is data/session.db
+func (p *SQLiteProvider) Init(maxlifetime int64, connStr string) (err error) {
+	p.maxlifetime = maxlifetime
+	if p.codec == nil {
+		p.codec = session.GobCodec{}
+	}
+	if connStr == "" {
+		connStr = "data/session.db"
+	}
+
+	p.c, err = sql.Open("sqlite3", connStr)
+	if err != nil {
+		return err
+	}
+	// sqlite 同时只允许一个写入, 共用一个连接避免 database is locked
+	p.c.SetMaxOpenConns(1)
+	_, err = p.c.Exec(`CREATE TABLE IF NOT EXISTS session (
+		key    TEXT    NOT NULL PRIMARY KEY,
+		data   BLOB,
+		expiry INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = p.c.Exec(`CREATE TABLE IF NOT EXISTS session_index (
+		uid TEXT NOT NULL,
+		sid TEXT NOT NULL,
+		PRIMARY KEY (uid, sid)
+	)`)
+	return err
+}
+
+// CodecSet sets codec of session data.
+func (p *SQLiteProvider) CodecSet(codec session.Codec) {
+	p.codec = codec
+}
+
+// Read returns raw session store by session ID.
+func (p *SQLiteProvider) Read(sid string) (session.RawStore, error) {
+	var data []byte
+	err := p.c.QueryRow("SELECT data FROM session WHERE key=?", sid).Scan(&data)
+	if err == sql.ErrNoRows {
+		_, err = p.c.Exec("INSERT INTO session(key,data,expiry) VALUES(?,?,?)",
+			sid, "", time.Now().Unix())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var kv map[interface{}]interface{}
+	if len(data) == 0 {
+		kv = make(map[interface{}]interface{})
+	} else {
+		kv, err = p.codec.Decode(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := NewSQLiteStore(p.c, sid, kv)
+	s.codec = p.codec
+	return s, nil
+}
+
+// Exist returns true if session with given ID exists.
+func (p *SQLiteProvider) Exist(sid string) bool {
+	var data []byte
+	err := p.c.QueryRow("SELECT data FROM session WHERE key=?", sid).Scan(&data)
+	if err != nil && err != sql.ErrNoRows {
+		panic("session/sqlite: error checking existence: " + err.Error())
+	}
+	return err != sql.ErrNoRows
+}
+
+// Destory deletes a session by session ID.
+func (p *SQLiteProvider) Destory(sid string) error {
+	_, err := p.c.Exec("DELETE FROM session WHERE key=?", sid)
+	return err
+}
+
+// Regenerate regenerates a session store from old session ID to new one.
+func (p *SQLiteProvider) Regenerate(oldsid, sid string) (_ session.RawStore, err error) {
+	if p.Exist(sid) {
+		return nil, fmt.Errorf("new sid '%s' already exists", sid)
+	}
+
+	if !p.Exist(oldsid) {
+		if _, err = p.c.Exec("INSERT INTO session(key,data,expiry) VALUES(?,?,?)",
+			oldsid, "", time.Now().Unix()); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = p.c.Exec("UPDATE session SET key=? WHERE key=?", sid, oldsid); err != nil {
+		return nil, err
+	}
+
+	return p.Read(sid)
+}
+
+// Count counts and returns number of sessions.
+func (p *SQLiteProvider) Count() (total int) {
+	if err := p.c.QueryRow("SELECT COUNT(*) AS NUM FROM session").Scan(&total); err != nil {
+		panic("session/sqlite: error counting records: " + err.Error())
+	}
+	return total
+}
+
+// GC calls GC to clean expired sessions.
+func (p *SQLiteProvider) GC() {
+	if _, err := p.c.Exec("DELETE FROM session WHERE expiry < ?", time.Now().Unix()-p.maxlifetime); err != nil {
+		log.Printf("session/sqlite: error garbage collecting: %v", err)
+	}
+	if _, err := p.c.Exec("DELETE FROM session_index WHERE sid NOT IN (SELECT key FROM session)"); err != nil {
+		log.Printf("session/sqlite: error garbage collecting index: %v", err)
+	}
+}
+
+// IndexAdd adds session ID to user ID.
+func (p *SQLiteProvider) IndexAdd(uid, sid string) error {
+	_, err := p.c.Exec("INSERT OR IGNORE INTO session_index(uid,sid) VALUES(?,?)", uid, sid)
+	return err
+}
+
+// IndexRemove removes session ID from user ID.
+func (p *SQLiteProvider) IndexRemove(uid, sid string) error {
+	_, err := p.c.Exec("DELETE FROM session_index WHERE uid=? AND sid=?", uid, sid)
+	return err
+}
+
+// IndexList returns session IDs of user ID.
+func (p *SQLiteProvider) IndexList(uid string) (sids []string, err error) {
+	rows, err := p.c.Query("SELECT sid FROM session_index WHERE uid=?", uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sid string
+		if err = rows.Scan(&sid); err != nil {
+			return nil, err
+		}
+		sids = append(sids, sid)
+	}
+	return sids, rows.Err()
+}
+
+func init() {
+	session.Register("sqlite", &SQLiteProvider{})
+}
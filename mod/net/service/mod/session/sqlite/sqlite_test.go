@@ -0,0 +1,12 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/net/service/mod/session/sessiontest"
+)
+
+func Test_Provider(t *testing.T) {
+	sessiontest.ProviderTest(t, &SQLiteProvider{}, filepath.Join(t.TempDir(), "session.db"))
+}
@@ -0,0 +1,146 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	ErrKeyNotFound = errors.New("session: key not found")
+)
+
+// 取值时兼容各 Codec 解码后的类型: gob 保留原类型, json 为 json.Number, msgpack 为各宽度的整数
+
+// GetString returns value of given key as string, "" if missing.
+func (s *store) GetString(key interface{}) string {
+	return toString(s.Get(key))
+}
+
+// GetInt returns value of given key as int, 0 if missing or not a number.
+func (s *store) GetInt(key interface{}) int {
+	return int(toInt64(s.Get(key)))
+}
+
+// GetInt64 returns value of given key as int64, 0 if missing or not a number.
+func (s *store) GetInt64(key interface{}) int64 {
+	return toInt64(s.Get(key))
+}
+
+// GetFloat64 returns value of given key as float64, 0 if missing or not a number.
+func (s *store) GetFloat64(key interface{}) float64 {
+	switch v := s.Get(key).(type) {
+	case nil:
+		return 0
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			return rv.Float()
+		}
+	}
+	return 0
+}
+
+// GetBool returns value of given key as bool, false if missing.
+func (s *store) GetBool(key interface{}) bool {
+	switch v := s.Get(key).(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// GetStruct stores value of given key in the value pointed to by v.
+// Values decoded as maps by json or msgpack codec are converted through JSON.
+func (s *store) GetStruct(key interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("session: GetStruct of non-pointer %T", v)
+	}
+	val := s.Get(key)
+	if val == nil {
+		return ErrKeyNotFound
+	}
+	elem := rv.Elem()
+	src := reflect.ValueOf(val)
+	if src.Type().AssignableTo(elem.Type()) {
+		elem.Set(src)
+		return nil
+	}
+	if src.Kind() == reflect.Ptr && !src.IsNil() && src.Elem().Type().AssignableTo(elem.Type()) {
+		elem.Set(src.Elem())
+		return nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("session: GetStruct of %T: %v", val, err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Pop returns value of given key and deletes it from session.
+func (s *store) Pop(key interface{}) interface{} {
+	v := s.Get(key)
+	if v != nil {
+		s.Delete(key)
+	}
+	return v
+}
+
+func toString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt64(val interface{}) int64 {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return int64(f)
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			return int64(rv.Float())
+		}
+	}
+	return 0
+}
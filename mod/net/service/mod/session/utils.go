@@ -20,9 +20,15 @@ import (
 	"crypto/rand"
 	"encoding/gob"
 	"io"
+	"reflect"
+	"sync"
+
 	"github.com/sail-services/sail-go/com/data/random"
 )
 
+// gobTypes holds value types already registered to gob.
+var gobTypes sync.Map
+
 func init() {
 	gob.Register([]interface{}{})
 	gob.Register(map[int]interface{}{})
@@ -32,17 +38,28 @@ func init() {
 	gob.Register(map[int]string{})
 	gob.Register(map[int]int{})
 	gob.Register(map[int]int64{})
+	gob.Register([]flashEntry{})
 }
 
 func EncodeGob(obj map[interface{}]interface{}) ([]byte, error) {
 	for _, v := range obj {
-		gob.Register(v)
+		gobRegister(v)
 	}
 	buf := bytes.NewBuffer(nil)
 	err := gob.NewEncoder(buf).Encode(obj)
 	return buf.Bytes(), err
 }
 
+// gobRegister registers type of value to gob once.
+func gobRegister(v interface{}) {
+	if v == nil {
+		return
+	}
+	if _, ok := gobTypes.LoadOrStore(reflect.TypeOf(v), true); !ok {
+		gob.Register(v)
+	}
+}
+
 func DecodeGob(encoded []byte) (out map[interface{}]interface{}, err error) {
 	buf := bytes.NewBuffer(encoded)
 	err = gob.NewDecoder(buf).Decode(&out)
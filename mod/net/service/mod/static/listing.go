@@ -0,0 +1,73 @@
+package static
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
+)
+
+type (
+	listEntry struct {
+		Name    string    `json:"name"`
+		Dir     bool      `json:"dir"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"mod_time"`
+	}
+)
+
+// 列出目录内容, 隐藏以 . 开头的文件, 文件名均经过转义
+func listing(con *service.Context, s *static, dir string) bool {
+	f, err := s.opt.FS.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		return false
+	}
+	entries := make([]listEntry, 0, len(infos))
+	for _, fi := range infos {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		entries = append(entries, listEntry{fi.Name(), fi.IsDir(), fi.Size(), fi.ModTime().UTC()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Dir != entries[j].Dir {
+			return entries[i].Dir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	con.Opt.Log = false
+	con.Resp.Header().Set("Cache-Control", "no-cache")
+	if s.opt.Listing == LISTING_JSON {
+		con.Ren.JSON(http.StatusOK, entries)
+		return true
+	}
+	title := html.EscapeString(con.Req.URL.Path)
+	b := new(strings.Builder)
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\" /><title>" + title + "</title></head>\n<body>\n<h1>" + title + "</h1>\n<ul>\n")
+	if dir != "/" {
+		b.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, e := range entries {
+		name := e.Name
+		size := strconv.FormatInt(e.Size, 10)
+		if e.Dir {
+			name += "/"
+			size = "-"
+		}
+		href := (&url.URL{Path: name}).String()
+		b.WriteString("<li><a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(name) + "</a> " + size + "</li>\n")
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+	con.Ren.HTML(http.StatusOK, []byte(b.String()))
+	return true
+}
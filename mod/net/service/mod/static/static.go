@@ -1,24 +1,64 @@
 package static
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
+	"os"
 	"path"
 	"path/filepath"
-	"github.com/sail-services/sail-go/mod/net/service"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/net/service"
 )
 
 type (
 	Options struct {
-		Prefix    string          // 前缀路径 [/]
-		Dir       string          // 文件夹 [static]
-		ShowLog   bool            // 显示日志 [false]
-		FS        http.FileSystem // 文件系统接口 [可定义]
-		IndexFile string          // 默认文件 [index.html]
+		Prefix        string                // 前缀路径 [/]
+		Dir           string                // 文件夹 [static]
+		ShowLog       bool                  // 显示日志 [false]
+		FS            http.FileSystem       // 文件系统接口 [可定义]
+		Files         fs.FS                 // fs.FS 文件系统, 如 embed.FS, 设置后忽略 Dir [nil]
+		IndexFile     string                // 默认文件 [index.html]
+		Preloads      []service.PreloadLink // HTML 默认预加载资源 [nil]
+		CacheControls [][]string            // 按路径匹配的 Cache-Control, 同时设置 Expires [[*.css, public, max-age=86400]] [nil]
+		Precompressed bool                  // 存在 .br/.gz 文件时按 Accept-Encoding 直接返回 [false]
+		Index         bool                  // 在内存中缓存已存在文件的信息, 文件变化后需重启 [false]
+		Fingerprint   bool                  // 支持 app.3f9a1c2b.css 形式的指纹路径, 返回长期缓存 [false]
+		AssetVar      string                // 模版中指纹路径函数的变量名 [asset]
+		Listing       string                // 无默认文件时列出目录 html / json, 为空时不列出 [nil]
 	}
 	staticFS struct {
 		dir *http.Dir
 	}
+	static struct {
+		opt    Options
+		lock   sync.RWMutex
+		index  map[string]*fileMeta
+		hashes map[string]*fileMeta
+	}
+	fileMeta struct {
+		exist   bool
+		dir     bool
+		modTime time.Time
+		br      bool
+		gz      bool
+		hash    string
+	}
+)
+
+const (
+	LISTING_HTML = "html"
+	LISTING_JSON = "json"
+
+	_CACHE_IMMUTABLE = "public, max-age=31536000, immutable"
+	_HASH_LENGTH     = 8
 )
 
 func New(opts ...Options) service.Handler {
@@ -26,12 +66,16 @@ func New(opts ...Options) service.Handler {
 	if len(opts) > 0 {
 		opt = opts[0]
 	}
-	opt = optPrepare(opt)
+	s := staticNew(optPrepare(opt))
+	asset := assetFunc([]*static{s})
 	return func(con *service.Context) {
-		if staticHandler(con, opt) {
+		if staticHandler(con, s) {
 			con.Opt.Stop = true
 			return
 		}
+		if s.opt.Fingerprint {
+			con.Var[s.opt.AssetVar] = asset
+		}
 	}
 }
 
@@ -39,64 +83,117 @@ func News(opts []Options) service.Handler {
 	if len(opts) == 0 {
 		panic("[Static] no static directory is given")
 	}
-	popts := make([]Options, len(opts))
+	statics := make([]*static, len(opts))
 	for i, opt := range opts {
-		popts[i] = optPrepare(opt)
+		statics[i] = staticNew(optPrepare(opt))
 	}
+	asset := assetFunc(statics)
 	return func(con *service.Context) {
-		for i := range popts {
-			if staticHandler(con, popts[i]) {
+		for _, s := range statics {
+			if staticHandler(con, s) {
 				con.Opt.Stop = true
 				return
 			}
 		}
+		for _, s := range statics {
+			if s.opt.Fingerprint {
+				con.Var[s.opt.AssetVar] = asset
+				return
+			}
+		}
 	}
 }
 
-func staticHandler(con *service.Context, opt Options) bool {
+func staticHandler(con *service.Context, s *static) bool {
 	if con.Req.Method != "GET" && con.Req.Method != "HEAD" {
 		return false
 	}
 	file := con.Req.URL.Path
-	if opt.Prefix != "" {
-		if !strings.HasPrefix(file, opt.Prefix) {
+	if s.opt.Prefix != "" {
+		if !strings.HasPrefix(file, s.opt.Prefix) {
 			return false
 		}
-		file = file[len(opt.Prefix):]
+		file = file[len(s.opt.Prefix):]
 		if file != "" && file[0] != '/' {
 			return false
 		}
 	}
-	f, err := opt.FS.Open(file)
-	if err != nil {
-		return false
+	file = path.Clean("/" + file)
+	meta := s.metaGet(file)
+	fingerprinted := false
+	if !meta.exist && s.opt.Fingerprint {
+		if name, hash := fingerprintSplit(file); hash != "" {
+			if m := s.metaGet(name); m.exist && !m.dir {
+				file, meta, fingerprinted = name, m, hash == s.hashGet(name)
+			}
+		}
 	}
-	defer f.Close()
-	fi, err := f.Stat()
-	if err != nil {
-		return true
+	if !meta.exist {
+		return false
 	}
-	if fi.IsDir() {
+	if meta.dir {
 		if !strings.HasSuffix(con.Req.URL.Path, "/") {
 			http.Redirect(con.Resp, con.Req.Request, con.Req.URL.Path+"/", http.StatusFound)
 			return true
 		}
-		file = path.Join(file, opt.IndexFile)
-		f, err = opt.FS.Open(file)
-		if err != nil {
-			return false
+		file = path.Join(file, s.opt.IndexFile)
+		meta = s.metaGet(file)
+		if !meta.exist {
+			if s.opt.Listing == "" {
+				return false
+			}
+			return listing(con, s, path.Dir(file))
 		}
-		defer f.Close()
-		fi, err = f.Stat()
-		if err != nil || fi.IsDir() {
+		if meta.dir {
 			return true
 		}
 	}
-	if opt.ShowLog && service.ModeIsDev() {
+	if s.opt.ShowLog && service.ModeIsDev() {
 		con.Log.Println("[Static] " + file)
 	}
 	con.Opt.Log = false
-	http.ServeContent(con.Resp, con.Req.Request, file, fi.ModTime(), f)
+	hd := con.Resp.Header()
+	if fingerprinted {
+		hd.Set("Cache-Control", _CACHE_IMMUTABLE)
+	} else if cc := s.cacheControl(file); cc != "" {
+		hd.Set("Cache-Control", cc)
+		if age := maxAge(cc); age >= 0 {
+			hd.Set("Expires", time.Now().Add(time.Duration(age)*time.Second).UTC().Format(http.TimeFormat))
+		}
+	}
+	if ext := path.Ext(file); ext == ".html" || ext == ".htm" {
+		con.Resp.Preload(s.opt.Preloads...)
+	}
+	serve := file
+	if s.opt.Precompressed && (meta.br || meta.gz) {
+		hd.Add("Vary", "Accept-Encoding")
+		accept := con.Req.Header.Get("Accept-Encoding")
+		if meta.br && encodingAccepted(accept, "br") {
+			serve = file + ".br"
+			hd.Set("Content-Encoding", "br")
+		} else if meta.gz && encodingAccepted(accept, "gzip") {
+			serve = file + ".gz"
+			hd.Set("Content-Encoding", "gzip")
+		}
+		if serve != file {
+			if ctype := mime.TypeByExtension(path.Ext(file)); ctype != "" {
+				hd.Set("Content-Type", ctype)
+			}
+		}
+	}
+	// 未修改时不打开文件
+	if service.ConditionCheck(con.Req.Request, "", meta.modTime) == http.StatusNotModified {
+		hd.Del("Content-Type")
+		hd.Set("Last-Modified", meta.modTime.UTC().Format(http.TimeFormat))
+		con.Resp.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	f, err := s.opt.FS.Open(serve)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	http.ServeContent(con.Resp, con.Req.Request, file, meta.modTime, f)
 	return true
 }
 
@@ -107,18 +204,123 @@ func optPrepare(opt Options) Options {
 	if opt.IndexFile == "" {
 		opt.IndexFile = "index.html"
 	}
+	if opt.AssetVar == "" {
+		opt.AssetVar = "asset"
+	}
 	if opt.Prefix != "" {
 		if opt.Prefix[0] != '/' {
 			opt.Prefix = "/" + opt.Prefix
 		}
 		opt.Prefix = strings.TrimRight(opt.Prefix, "/")
 	}
-	if opt.FS == nil {
+	if opt.Files != nil {
+		opt.FS = http.FS(opt.Files)
+	} else if opt.FS == nil {
 		opt.FS = staticFSNew(opt.Dir)
 	}
+	if opt.Listing != "" && opt.Listing != LISTING_HTML && opt.Listing != LISTING_JSON {
+		panic("[Static] unknown listing mode " + opt.Listing)
+	}
+	for _, cc := range opt.CacheControls {
+		if len(cc) < 2 {
+			panic("[Static] CacheControls needs [pattern, value]")
+		}
+		if _, err := path.Match(cc[0], ""); err != nil {
+			panic("[Static] bad CacheControls pattern " + cc[0])
+		}
+	}
 	return opt
 }
 
+// ========================================================
+// static
+// ========================================================
+func staticNew(opt Options) *static {
+	return &static{
+		opt:    opt,
+		index:  make(map[string]*fileMeta),
+		hashes: make(map[string]*fileMeta),
+	}
+}
+
+// 开启 Index 时只在首次访问时读取文件信息, 不存在的路径不缓存, 避免任意路径使 index 无限增长
+func (s *static) metaGet(file string) *fileMeta {
+	if s.opt.Index {
+		s.lock.RLock()
+		meta, ok := s.index[file]
+		s.lock.RUnlock()
+		if ok {
+			return meta
+		}
+	}
+	meta := &fileMeta{}
+	if fi, err := s.stat(file); err == nil {
+		meta.exist, meta.dir, meta.modTime = true, fi.IsDir(), fi.ModTime()
+		if s.opt.Precompressed && !meta.dir {
+			_, err = s.stat(file + ".br")
+			meta.br = err == nil
+			_, err = s.stat(file + ".gz")
+			meta.gz = err == nil
+		}
+	}
+	if s.opt.Index && meta.exist {
+		s.lock.Lock()
+		s.index[file] = meta
+		s.lock.Unlock()
+	}
+	return meta
+}
+
+func (s *static) stat(file string) (os.FileInfo, error) {
+	f, err := s.opt.FS.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// 文件内容的摘要, 按修改时间缓存
+func (s *static) hashGet(file string) string {
+	meta := s.metaGet(file)
+	if !meta.exist || meta.dir {
+		return ""
+	}
+	s.lock.RLock()
+	cached, ok := s.hashes[file]
+	s.lock.RUnlock()
+	if ok && cached.modTime.Equal(meta.modTime) {
+		return cached.hash
+	}
+	f, err := s.opt.FS.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return ""
+	}
+	hash := hex.EncodeToString(h.Sum(nil))[:_HASH_LENGTH]
+	s.lock.Lock()
+	s.hashes[file] = &fileMeta{exist: true, modTime: meta.modTime, hash: hash}
+	s.lock.Unlock()
+	return hash
+}
+
+func (s *static) cacheControl(file string) string {
+	base := path.Base(file)
+	for _, cc := range s.opt.CacheControls {
+		if ok, _ := path.Match(cc[0], file); ok {
+			return strings.Join(cc[1:], ", ")
+		}
+		if ok, _ := path.Match(cc[0], base); ok {
+			return strings.Join(cc[1:], ", ")
+		}
+	}
+	return ""
+}
+
 // ========================================================
 // staticFS
 // ========================================================
@@ -133,3 +335,77 @@ func staticFSNew(directory string) staticFS {
 func (fs staticFS) Open(name string) (http.File, error) {
 	return fs.dir.Open(name)
 }
+
+// --------------------------------------------------------
+// FUNC
+// --------------------------------------------------------
+// 模版中使用 {{asset "app.css"}} 得到 /s/app.3f9a1c2b.css, 文件不存在时返回原路径
+func assetFunc(statics []*static) func(name string) string {
+	return func(name string) string {
+		file := path.Clean("/" + name)
+		for _, s := range statics {
+			if !s.opt.Fingerprint {
+				continue
+			}
+			if hash := s.hashGet(file); hash != "" {
+				return s.opt.Prefix + fingerprintJoin(file, hash)
+			}
+		}
+		for _, s := range statics {
+			if s.opt.Fingerprint {
+				return s.opt.Prefix + file
+			}
+		}
+		return file
+	}
+}
+
+func fingerprintJoin(file, hash string) string {
+	ext := path.Ext(file)
+	return file[:len(file)-len(ext)] + "." + hash + ext
+}
+
+// /app.3f9a1c2b.css -> /app.css, 3f9a1c2b
+func fingerprintSplit(file string) (string, string) {
+	ext := path.Ext(file)
+	rest := file[:len(file)-len(ext)]
+	i := strings.LastIndexByte(rest, '.')
+	if i < 0 || len(rest)-i-1 != _HASH_LENGTH || strings.LastIndexByte(rest, '/') > i {
+		return file, ""
+	}
+	hash := rest[i+1:]
+	if _, err := hex.DecodeString(hash); err != nil {
+		return file, ""
+	}
+	return rest[:i] + ext, hash
+}
+
+func encodingAccepted(accept, name string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), name) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func maxAge(cache_control string) int {
+	for _, directive := range strings.Split(cache_control, ",") {
+		directive = strings.TrimSpace(directive)
+		if strings.HasPrefix(directive, "max-age=") {
+			if age, err := strconv.Atoi(directive[len("max-age="):]); err == nil {
+				return age
+			}
+		}
+	}
+	return -1
+}
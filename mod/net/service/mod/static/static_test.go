@@ -0,0 +1,143 @@
+package static_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/static"
+)
+
+func serviceNew(t *testing.T) (*service.Service, func()) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "app.css"), []byte("body{}"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "app.css.gz"), []byte("gzipped"), 0644)
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(static.New(static.Options{
+		Prefix:        "/s",
+		Dir:           dir,
+		Index:         true,
+		Precompressed: true,
+		Fingerprint:   true,
+		CacheControls: [][]string{{"*.css", "public", "max-age=60"}},
+	}))
+	ser.Rou.Get("/page", func(con *service.Context) {
+		con.Ren.S(200, con.Var["asset"].(func(string) string)("app.css"))
+	})
+	return ser, func() { os.RemoveAll(dir) }
+}
+
+func serve(ser *service.Service, url string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", url, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_CacheAndPrecompressed(t *testing.T) {
+	ser, clean := serviceNew(t)
+	defer clean()
+	rec := serve(ser, "/s/app.css")
+	if rec.Body.String() != "body{}" || rec.Header().Get("Cache-Control") != "public, max-age=60" || rec.Header().Get("Expires") == "" {
+		t.Errorf("plain: %q %v", rec.Body.String(), rec.Header())
+	}
+	rec = serve(ser, "/s/app.css", "Accept-Encoding", "gzip")
+	if rec.Body.String() != "gzipped" || rec.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("gzip: %q %v", rec.Body.String(), rec.Header())
+	}
+	rec = serve(ser, "/s/app.css", "If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if rec.Code != 304 {
+		t.Errorf("not modified: %d", rec.Code)
+	}
+}
+
+func Test_IndexMiss(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(static.New(static.Options{Prefix: "/s", Dir: dir, Index: true}))
+	if rec := serve(ser, "/s/late.js"); rec.Code != 404 {
+		t.Fatalf("missing file: %d", rec.Code)
+	}
+	// 不存在的路径不进入 index, 之后创建的文件可以访问
+	ioutil.WriteFile(filepath.Join(dir, "late.js"), []byte("late"), 0644)
+	if rec := serve(ser, "/s/late.js"); rec.Code != 200 || rec.Body.String() != "late" {
+		t.Errorf("file created after miss: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func Test_Fingerprint(t *testing.T) {
+	ser, clean := serviceNew(t)
+	defer clean()
+	url := serve(ser, "/page").Body.String()
+	if !strings.HasPrefix(url, "/s/app.") || len(url) != len("/s/app.12345678.css") {
+		t.Fatalf("asset url %q", url)
+	}
+	rec := serve(ser, url)
+	if rec.Body.String() != "body{}" || !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("fingerprinted: %q %v", rec.Body.String(), rec.Header())
+	}
+	if rec = serve(ser, "/s/app.00000000.css"); strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("stale fingerprint cached forever: %v", rec.Header())
+	}
+}
+
+func Test_FilesAndListing(t *testing.T) {
+	files := fstest.MapFS{
+		"docs/a<b>.txt":  {Data: []byte("a")},
+		"docs/.secret":   {Data: []byte("s")},
+		"docs/sub/c.txt": {Data: []byte("c")},
+	}
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(static.News([]static.Options{
+		{Prefix: "/html", Files: files, Listing: static.LISTING_HTML},
+		{Prefix: "/json", Files: files, Listing: static.LISTING_JSON},
+	}))
+	if rec := serve(ser, "/html/docs/sub/c.txt"); rec.Body.String() != "c" {
+		t.Errorf("fs file: %q", rec.Body.String())
+	}
+	body := serve(ser, "/html/docs/").Body.String()
+	if !strings.Contains(body, `<a href="a%3Cb%3E.txt">a&lt;b&gt;.txt</a>`) || !strings.Contains(body, `<a href="sub/">sub/</a>`) || strings.Contains(body, "secret") {
+		t.Errorf("html listing: %s", body)
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(serve(ser, "/json/docs/").Body.Bytes(), &entries); err != nil || len(entries) != 2 || entries[0]["name"] != "sub" {
+		t.Errorf("json listing: %v %v", entries, err)
+	}
+}
+
+func Test_Preloads(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html": {Data: []byte("<html></html>")},
+		"app.css":    {Data: []byte("body{}")},
+	}
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(static.New(static.Options{
+		Files:    files,
+		Preloads: []service.PreloadLink{{URL: "/app.css", As: "style"}},
+	}))
+	if links := serve(ser, "/index.html").Header()["Link"]; len(links) != 1 || links[0] != "</app.css>; rel=preload; as=style" {
+		t.Errorf("html Link = %q", links)
+	}
+	if links := serve(ser, "/app.css").Header()["Link"]; len(links) != 0 {
+		t.Errorf("css Link = %q", links)
+	}
+}
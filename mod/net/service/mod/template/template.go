@@ -3,14 +3,15 @@ package template
 import (
 	"html/template"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strings"
+	"sync"
+
 	"github.com/sail-services/sail-go/com/data/buffer"
 	"github.com/sail-services/sail-go/com/data/convert"
 	"github.com/sail-services/sail-go/mod/net/service"
-	"strings"
-	"sync"
 )
 
 type (
@@ -20,6 +21,7 @@ type (
 		Charset    string   // 字符集 [UTF-8]
 		DelimLeft  string   // 模版左符号 [nil]
 		DelimRight string   // 模版右符号 [nil]
+		FS         fs.FS    // 模版文件系统, 如 embed.FS [os.DirFS(Dir)]
 	}
 	renderTemplate struct {
 		lock sync.RWMutex
@@ -60,17 +62,21 @@ func templateCompile(opt *Options) *template.Template {
 		t.Delims(opt.DelimLeft, opt.DelimRight)
 	}
 	template.Must(t.Parse(service.ModeGet()))
-	if err := filepath.Walk(opt.Dir, func(path string, info os.FileInfo, err error) error {
-		r, err := filepath.Rel(opt.Dir, path)
+	if err := fs.WalkDir(opt.FS, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		ext := getExt(r)
+		if d.IsDir() {
+			return nil
+		}
+		ext := getExt(path)
 		for _, extension := range opt.Ext {
 			if ext == extension {
+				data, err := fs.ReadFile(opt.FS, path)
 				if err != nil {
 					panic(err)
 				}
+				template.Must(t.New(path[:len(path)-len(ext)]).Parse(convert.BToS(data)))
 				break
 			}
 		}
@@ -95,6 +101,9 @@ func optPrepare(opts []Options) Options {
 	if opt.Charset == "" {
 		opt.Charset = "UTF-8"
 	}
+	if opt.FS == nil {
+		opt.FS = os.DirFS(opt.Dir)
+	}
 	return opt
 }
 
@@ -0,0 +1,30 @@
+package template_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+	"github.com/sail-services/sail-go/mod/net/service"
+	"github.com/sail-services/sail-go/mod/net/service/mod/template"
+)
+
+func Test_FS(t *testing.T) {
+	files := fstest.MapFS{
+		"user/index.html": {Data: []byte(`{{define "title"}}T{{end}}Hello {{.name}}`)},
+		"readme.md":       {Data: []byte("ignored")},
+	}
+	ser := service.New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+	ser.Module(template.New(template.Options{FS: files}))
+	ser.Rou.Get("/", func(con *service.Context) {
+		con.Var["name"] = "<sail>"
+		con.Ren.Tpl(200, "user/index")
+	})
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Body.String() != "Hello &lt;sail&gt;" {
+		t.Errorf("body %q", rec.Body.String())
+	}
+}
@@ -18,8 +18,9 @@ import (
 type (
 	Request struct {
 		*http.Request
-		params reqParams
-		con    *Context
+		params  reqParams
+		pattern string
+		con     *Context
 	}
 	RequestBody struct {
 		reader io.ReadCloser
@@ -34,6 +35,11 @@ func (req *Request) Body() *RequestBody {
 	return &RequestBody{req.Request.Body}
 }
 
+// 匹配的路由模式, 如 /user/:id, 未匹配时为空
+func (req *Request) Pattern() string {
+	return req.pattern
+}
+
 func (req *Request) IP() string {
 	ip := req.Header.Get("X-Real-IP")
 	if ip == "" {
@@ -109,6 +115,9 @@ func (req *Request) ParamSet(name, val string) {
 	if !strings.HasPrefix(name, ":") {
 		name = ":" + name
 	}
+	if req.params == nil {
+		req.params = make(reqParams)
+	}
 	req.params[name] = val
 }
 
@@ -7,8 +7,10 @@ import (
 	"net"
 	"net/http"
 	"net/url"
-	"github.com/sail-services/sail-go/com/data/crypt/aes"
+	"strings"
 	"time"
+
+	"github.com/sail-services/sail-go/com/data/crypt/aes"
 )
 
 type (
@@ -23,14 +25,23 @@ type (
 		Before(func(Response))
 		CookieSet(name, value string, others ...interface{})
 		SecureCookieSet(name, value string, others ...interface{})
+		ETag(tag string) bool
+		LastModified(t time.Time) bool
+		Preload(links ...PreloadLink)
+		Push(target string) error
 		writeHeader()
 	}
+	PreloadLink struct {
+		URL string // 资源路径
+		As  string // 资源类型, 如 script, style, font [""]
+	}
 	response struct {
 		http.ResponseWriter
 		con         *Context
 		size        int
 		status      int
 		beforeFuncs []func(Response)
+		hinted      bool // 已发送 103 Early Hints
 	}
 )
 
@@ -59,16 +70,21 @@ func (resp *response) reset(http_resp http.ResponseWriter, con *Context) {
 	resp.size = -1
 	resp.status = 200
 	resp.beforeFuncs = nil
+	resp.hinted = false
 }
 
+// 在写入 Header 前调用一次, 后注册的先调用
 func (resp *response) callBefore() {
-	for i := len(resp.beforeFuncs) - 1; i >= 0; i-- {
-		resp.beforeFuncs[i](resp)
+	funcs := resp.beforeFuncs
+	resp.beforeFuncs = nil
+	for i := len(funcs) - 1; i >= 0; i-- {
+		funcs[i](resp)
 	}
 }
 
 func (resp *response) writeHeader() {
 	if !resp.IsWritten() {
+		resp.callBefore()
 		resp.size = 0
 		resp.ResponseWriter.WriteHeader(resp.status)
 	}
@@ -77,6 +93,7 @@ func (resp *response) writeHeader() {
 // --------------------------------------------------------
 // response - Cookie
 // --------------------------------------------------------
+// others 依次为 MaxAge, Path, Domain, Secure, HttpOnly, SameSite
 func (resp *response) CookieSet(name, value string, others ...interface{}) {
 	cookie := http.Cookie{}
 	cookie.Name = name
@@ -120,6 +137,11 @@ func (resp *response) CookieSet(name, value string, others ...interface{}) {
 			cookie.HttpOnly = true
 		}
 	}
+	if len(others) > 5 {
+		if v, ok := others[5].(http.SameSite); ok {
+			cookie.SameSite = v
+		}
+	}
 	resp.Header().Add("Set-Cookie", cookie.String())
 }
 
@@ -141,6 +163,136 @@ func (resp *response) SecureCookieSet(name, value string, others ...interface{})
 	resp.CookieSet(name, hex.EncodeToString(text), others...)
 }
 
+// --------------------------------------------------------
+// response - Condition
+// --------------------------------------------------------
+// 设置 ETag, 返回 true 表示已按条件请求返回 304/412, 处理可直接结束
+func (resp *response) ETag(tag string) bool {
+	if !strings.HasSuffix(tag, `"`) {
+		tag = `"` + tag + `"`
+	}
+	resp.Header().Set("ETag", tag)
+	return resp.condition()
+}
+
+// 设置 Last-Modified, 返回 true 表示已按条件请求返回 304/412, 处理可直接结束
+func (resp *response) LastModified(t time.Time) bool {
+	if !t.IsZero() {
+		resp.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+	return resp.condition()
+}
+
+func (resp *response) condition() bool {
+	hd := resp.Header()
+	modified, _ := http.ParseTime(hd.Get("Last-Modified"))
+	status := ConditionCheck(resp.con.Req.Request, hd.Get("ETag"), modified)
+	if status == 0 {
+		return false
+	}
+	if status == http.StatusNotModified {
+		hd.Del("Content-Type")
+		hd.Del("Content-Length")
+	}
+	resp.con.Abort(status)
+	return true
+}
+
+// 按 RFC 7232 检查条件请求, 返回 304, 412 或 0 (继续处理)
+func ConditionCheck(req *http.Request, etag string, modified time.Time) int {
+	safe := req.Method == "GET" || req.Method == "HEAD"
+	modified = modified.Truncate(time.Second)
+	if im := req.Header.Get("If-Match"); im != "" {
+		if !etagMatch(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil && !modified.IsZero() {
+		if modified.After(ius) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if etagMatch(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && safe && !modified.IsZero() {
+		if !modified.After(ims) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+func etagMatch(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "W/") {
+			if !weak {
+				continue
+			}
+			v = v[2:]
+		}
+		if v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// --------------------------------------------------------
+// response - Preload
+// --------------------------------------------------------
+// 添加 Link: rel=preload, HTTP/1.1 及以上同时发送只含 Link 的 103 Early Hints.
+// 每个响应只发送一次 103, 之后添加的 Link 只在最终响应中, 应一次传入全部资源
+func (resp *response) Preload(links ...PreloadLink) {
+	if len(links) == 0 {
+		return
+	}
+	for _, l := range links {
+		link := "<" + l.URL + ">; rel=preload"
+		if l.As != "" {
+			link += "; as=" + l.As
+		}
+		resp.Header().Add("Link", link)
+	}
+	if !resp.hinted && !resp.IsWritten() && resp.con.Req.ProtoAtLeast(1, 1) {
+		resp.hinted = true
+		// 103 会发送当前所有 Header, 暂时只保留 Link, 发送后恢复
+		hd := resp.Header()
+		saved := hd.Clone()
+		for name := range hd {
+			if name != "Link" {
+				delete(hd, name)
+			}
+		}
+		resp.ResponseWriter.WriteHeader(http.StatusEarlyHints)
+		for name, vals := range saved {
+			hd[name] = vals
+		}
+	}
+}
+
+// HTTP/2 服务端推送, 不支持时返回 http.ErrNotSupported
+func (resp *response) Push(target string) error {
+	if pusher, ok := resp.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, nil)
+	}
+	return http.ErrNotSupported
+}
+
 // --------------------------------------------------------
 // response - GO
 // --------------------------------------------------------
@@ -153,7 +305,6 @@ func (resp *response) Write(data []byte) (n int, err error) {
 
 func (resp *response) WriteHeader(code int) {
 	if code > 0 {
-		resp.callBefore()
 		resp.status = code
 		if resp.IsWritten() {
 			resp.con.Log.Errorln("WriteHeader Error")
@@ -171,5 +322,6 @@ func (resp *response) CloseNotify() <-chan bool {
 }
 
 func (resp *response) Flush() {
+	resp.writeHeader()
 	resp.ResponseWriter.(http.Flusher).Flush()
 }
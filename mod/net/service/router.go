@@ -1,14 +1,17 @@
 package service
 
 import (
+	"io/fs"
 	"net/http"
 	"path"
-	"github.com/sail-services/sail-go/com/base"
-	"github.com/sail-services/sail-go/com/data/convert"
-	estr "github.com/sail-services/sail-go/com/data/strings"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/sail-services/sail-go/com/base"
+	"github.com/sail-services/sail-go/com/data/convert"
+	estr "github.com/sail-services/sail-go/com/data/strings"
 )
 
 type (
@@ -16,14 +19,25 @@ type (
 		ser          *Service
 		absolutePath string
 		routers      map[string]*proTree
-		groups       []proGroup
+		groups       []*RouterGroup
 		notFound     http.HandlerFunc
 		*proMap
 	}
-	proGroup struct {
+	RouterGroup struct {
+		ser      *Service
 		pattern  string
 		handlers []Handler
 	}
+	proRoute struct {
+		ser      *Service
+		groups   []*RouterGroup
+		handlers []Handler
+		chain    atomic.Value
+	}
+	proChain struct {
+		version  uint32
+		handlers []Handler
+	}
 	proCombo struct {
 		router   *routerPro
 		pattern  string
@@ -86,24 +100,18 @@ func (rou *routerPro) init(ser *Service) {
 	rou.absolutePath = _PATH_ROOT
 	rou.routers = make(map[string]*proTree)
 	rou.proMap = proMapNew()
-	var not_found_func []Handler
-	not_found_func = append(not_found_func, func(con *Context) {
+	rou.NotFound(func(con *Context) {
 		con.Ren.S(404, _E404)
 	})
-	rou.notFound = func(resp http.ResponseWriter, req *http.Request) {
-		hds := rou.ser.modsCombine(not_found_func)
-		con := rou.ser.contextNew(resp, req, hds)
-		con.Resp.WriteHeader(404)
-		con.Next()
-		con.Resp.writeHeader()
-		rou.ser.pool.Put(con)
-	}
 }
 
-func (rou *routerPro) Group(rpath string, function func(), hds ...Handler) {
-	rou.groups = append(rou.groups, proGroup{rpath, hds})
+// 返回的 RouterGroup 可继续 Use, 对组内已注册的路由同样生效
+func (rou *routerPro) Group(rpath string, function func(), hds ...Handler) *RouterGroup {
+	g := &RouterGroup{rou.ser, rpath, hds}
+	rou.groups = append(rou.groups, g)
 	function()
 	rou.groups = rou.groups[:len(rou.groups)-1]
+	return g
 }
 
 func (rou *routerPro) Get(rpath string, hds ...Handler) {
@@ -173,10 +181,28 @@ func (rou *routerPro) File(rpath, fpath string) {
 	})
 }
 
+// 从 fs.FS (如 embed.FS) 中返回单个文件
+func (rou *routerPro) FileFS(rpath string, fsys fs.FS, name string) {
+	full_pattern := rou.calculateAbsolutePath(rpath)
+	if len(rou.groups) > 0 {
+		group_pattern := ""
+		for _, g := range rou.groups {
+			group_pattern += g.pattern
+		}
+		full_pattern = group_pattern + rpath
+	}
+	if ModeIsDev() {
+		rou.ser.Log.Infof("%v %v -> fs:%v\n", "GET", full_pattern, name)
+	}
+	rou.handle("GET", full_pattern, func(resp http.ResponseWriter, req *http.Request, params reqParams) {
+		http.ServeFileFS(resp, req, fsys, name)
+	})
+}
+
 func (rou *routerPro) NotFound(hds ...Handler) {
-	hds = rou.ser.modsCombine(hds)
+	route := routeNew(rou.ser, nil, hds)
 	rou.notFound = func(resp http.ResponseWriter, req *http.Request) {
-		con := rou.ser.contextNew(resp, req, hds)
+		con := rou.ser.contextNew(resp, req, route.handlersGet())
 		con.Resp.WriteHeader(404)
 		con.Next()
 		con.Resp.writeHeader()
@@ -188,22 +214,21 @@ func (rou *routerPro) Handle(method string, rpath string, hds []Handler) {
 	full_pattern := rpath
 	if len(rou.groups) > 0 {
 		group_pattern := ""
-		h := make([]Handler, 0)
 		for _, g := range rou.groups {
 			group_pattern += g.pattern
-			h = append(h, g.handlers...)
 		}
 		full_pattern = group_pattern + rpath
-		h = append(h, hds...)
-		hds = h
 	}
-	hds = rou.ser.modsCombine(hds)
+	route := routeNew(rou.ser, rou.groups, hds)
 	if ModeIsDev() {
-		rou.ser.Log.Infof("%v %v -> %v (%v)\n", method, full_pattern, base.FuncNameGet(hds[len(hds)-1]), len(hds))
+		chain := route.handlersGet()
+		rou.ser.Log.Infof("%v %v -> %v (%v)\n", method, full_pattern, base.FuncNameGet(chain[len(chain)-1]), len(chain))
 	}
+	rou.ser.routes++
 	rou.handle(method, full_pattern, func(resp http.ResponseWriter, req *http.Request, params reqParams) {
-		con := rou.ser.contextNew(resp, req, hds)
+		con := rou.ser.contextNew(resp, req, route.handlersGet())
 		con.Req.params = params
+		con.Req.pattern = full_pattern
 		con.Next()
 		con.Resp.writeHeader()
 		rou.ser.pool.Put(con)
@@ -267,6 +292,39 @@ func (r *routerPro) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	r.notFound(rw, req)
 }
 
+// ========================================================
+// RouterGroup
+// ========================================================
+func (g *RouterGroup) Use(hds ...Handler) *RouterGroup {
+	g.handlers = append(g.handlers, hds...)
+	g.ser.versionIncr()
+	return g
+}
+
+// ========================================================
+// proRoute
+// ========================================================
+func routeNew(ser *Service, groups []*RouterGroup, hds []Handler) *proRoute {
+	route := &proRoute{ser: ser, handlers: hds}
+	route.groups = append(route.groups, groups...)
+	return route
+}
+
+// 处理链在 Module/Use 变更后的首次请求时重建
+func (route *proRoute) handlersGet() []Handler {
+	version := route.ser.versionGet()
+	if c, ok := route.chain.Load().(proChain); ok && c.version == version {
+		return c.handlers
+	}
+	hds := make([]Handler, 0)
+	for _, g := range route.groups {
+		hds = append(hds, g.handlers...)
+	}
+	hds = route.ser.modsCombine(append(hds, route.handlers...))
+	route.chain.Store(proChain{version, hds})
+	return hds
+}
+
 // ========================================================
 // proCombo
 // ========================================================
@@ -1,9 +1,11 @@
 package service
 
 import (
+	"io/fs"
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/sail-services/sail-go/com/data/convert"
 	"github.com/sail-services/sail-go/mod/data/log"
@@ -11,15 +13,17 @@ import (
 
 type (
 	Service struct {
-		Rou  Router
-		Log  *log.Log
-		pool sync.Pool
-		mods []Handler
+		Rou     Router
+		Log     *log.Log
+		pool    sync.Pool
+		mods    []Handler
+		version uint32 // 中间件版本, Module/Use 时递增, 路由据此重建处理链
+		routes  int
 	}
 	Router interface {
 		http.Handler
 		init(ser *Service)
-		Group(rpath string, function func(), hds ...Handler)
+		Group(rpath string, function func(), hds ...Handler) *RouterGroup
 		Get(rpath string, hds ...Handler)
 		Post(rpath string, hds ...Handler)
 		Put(rpath string, hds ...Handler)
@@ -31,6 +35,7 @@ type (
 		Unlink(rpath string, hds ...Handler)
 		Any(rpath string, hds ...Handler)
 		File(rpath, fpath string)
+		FileFS(rpath string, fsys fs.FS, name string)
 		NotFound(hds ...Handler)
 	}
 	Handler func(*Context)
@@ -130,8 +135,21 @@ func (ser *Service) Run(args ...interface{}) {
 	}
 }
 
+// 模块在构建处理链时合并, 已注册的路由同样生效
 func (ser *Service) Module(hds ...Handler) {
+	if ser.routes > 0 {
+		ser.Log.Warnf("Module added after %v routes, rebuilding handler chains\n", ser.routes)
+	}
 	ser.mods = append(ser.mods, hds...)
+	ser.versionIncr()
+}
+
+func (ser *Service) versionIncr() {
+	atomic.AddUint32(&ser.version, 1)
+}
+
+func (ser *Service) versionGet() uint32 {
+	return atomic.LoadUint32(&ser.version)
 }
 
 func (ser *Service) modsCombine(hds []Handler) []Handler {
@@ -143,9 +161,12 @@ func (ser *Service) modsCombine(hds []Handler) []Handler {
 
 func (ser *Service) contextNew(resp http.ResponseWriter, req *http.Request, hds []Handler) *Context {
 	con := ser.pool.Get().(*Context)
+	con.Log = ser.Log
 	con.resp.reset(resp, con)
 	con.Resp = &con.resp
 	con.Req.Request = req
+	con.Req.params = nil
+	con.Req.pattern = ""
 	con.Req.con = con
 	con.Ren.con = con
 	con.Opt.Log = true
@@ -154,6 +175,7 @@ func (ser *Service) contextNew(resp http.ResponseWriter, req *http.Request, hds
 	con.data = make(map[string]interface{})
 	con.handlers = hds
 	con.handlerIndex = -1
+	con.err = nil
 	return con
 }
 
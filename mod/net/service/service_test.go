@@ -0,0 +1,139 @@
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sail-services/sail-go/mod/data/log"
+)
+
+func serviceNew() *Service {
+	return New(log.New(ioutil.Discard, log.LEVEL_INFO, log.DATA_NONE))
+}
+
+func serve(ser *Service, method, url string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	ser.Rou.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+	return rec
+}
+
+func Test_ModuleAfterRoute(t *testing.T) {
+	ser := serviceNew()
+	ser.Rou.Get("/", func(con *Context) {
+		con.Ren.S(200, con.Resp.Header().Get("X-Mod"))
+	})
+	ser.Module(func(con *Context) {
+		con.Resp.Header().Set("X-Mod", "late")
+	})
+	if body := serve(ser, "GET", "/").Body.String(); body != "late" {
+		t.Errorf("module added after route not applied, got %q", body)
+	}
+	if body := serve(ser, "GET", "/none").Body.String(); body != _E404 {
+		t.Errorf("not found handler broken, got %q", body)
+	}
+}
+
+func Test_GroupUse(t *testing.T) {
+	ser := serviceNew()
+	order := ""
+	g := ser.Rou.Group("/api", func() {
+		ser.Rou.Get("/user", func(con *Context) {
+			order += "h"
+			con.Ren.S(200, order)
+		})
+	}, func(con *Context) {
+		order += "g"
+	})
+	g.Use(func(con *Context) {
+		order += "u"
+	})
+	rec := serve(ser, "GET", "/api/user")
+	if rec.Code != http.StatusOK || rec.Body.String() != "guh" {
+		t.Errorf("group Use not applied in order, got %v %q", rec.Code, rec.Body.String())
+	}
+}
+
+func Test_AbortLongChain(t *testing.T) {
+	ser := serviceNew()
+	calls := 0
+	hds := make([]Handler, 0, 300)
+	for i := 0; i < 299; i++ {
+		hds = append(hds, func(con *Context) {
+			calls++
+			if calls == 200 {
+				con.AbortWithStatusJSON(403, map[string]string{"error": "stop"})
+			}
+		})
+	}
+	hds = append(hds, func(con *Context) {
+		t.Error("handler after Abort was called")
+	})
+	ser.Rou.Get("/", hds...)
+	rec := serve(ser, "GET", "/")
+	if calls != 200 || rec.Code != 403 {
+		t.Errorf("abort in long chain failed, calls %v status %v", calls, rec.Code)
+	}
+}
+
+func Test_AbortNested(t *testing.T) {
+	ser := serviceNew()
+	after := false
+	ser.Module(func(con *Context) {
+		con.Next()
+		after = con.IsAborted()
+	})
+	ser.Rou.Get("/", func(con *Context) {
+		con.AbortWithError(500, http.ErrBodyNotAllowed)
+	}, func(con *Context) {
+		t.Error("handler after AbortWithError was called")
+	})
+	rec := serve(ser, "GET", "/")
+	if !after || rec.Code != 500 {
+		t.Errorf("nested abort not visible to module, aborted %v status %v", after, rec.Code)
+	}
+}
+
+// 记录 1xx 响应, 不传给 ResponseRecorder
+type hintRecorder struct {
+	*httptest.ResponseRecorder
+	hints []http.Header
+}
+
+func (rec *hintRecorder) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		rec.hints = append(rec.hints, rec.Header().Clone())
+		return
+	}
+	rec.ResponseRecorder.WriteHeader(code)
+}
+
+func Test_Preload(t *testing.T) {
+	ser := serviceNew()
+	ser.Rou.Get("/", func(con *Context) {
+		con.Resp.CookieSet("sid", "secret")
+		con.Resp.Preload(PreloadLink{URL: "/app.css", As: "style"}, PreloadLink{URL: "/app.js", As: "script"})
+		con.Resp.Preload(PreloadLink{URL: "/font.woff2"})
+		con.Resp.Write([]byte("ok"))
+	})
+	for _, proto := range []struct{ major, minor, hints int }{{1, 0, 0}, {1, 1, 1}, {2, 0, 1}} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.ProtoMajor, req.ProtoMinor = proto.major, proto.minor
+		rec := &hintRecorder{ResponseRecorder: httptest.NewRecorder()}
+		ser.Rou.ServeHTTP(rec, req)
+		links := rec.Header()["Link"]
+		if len(links) != 3 || links[0] != "</app.css>; rel=preload; as=style" || links[2] != "</font.woff2>; rel=preload" {
+			t.Errorf("HTTP/%d.%d Link = %q", proto.major, proto.minor, links)
+		}
+		if rec.Header().Get("Set-Cookie") == "" {
+			t.Errorf("HTTP/%d.%d Set-Cookie lost after early hints", proto.major, proto.minor)
+		}
+		if len(rec.hints) != proto.hints {
+			t.Fatalf("HTTP/%d.%d sent %d early hints", proto.major, proto.minor, len(rec.hints))
+		}
+		if proto.hints == 1 && (len(rec.hints[0]) != 1 || len(rec.hints[0]["Link"]) != 2) {
+			t.Errorf("HTTP/%d.%d early hints = %v", proto.major, proto.minor, rec.hints[0])
+		}
+	}
+}
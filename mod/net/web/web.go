@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/base64"
+	"io/fs"
 	"os"
 	"strings"
 
@@ -10,12 +11,16 @@ import (
 	rr_log "github.com/sail-services/sail-go/mod/data/log"
 	"github.com/sail-services/sail-go/mod/net/service"
 	"github.com/sail-services/sail-go/mod/net/service/mod/csrf"
-	"github.com/sail-services/sail-go/mod/net/service/mod/gzip"
+	"github.com/sail-services/sail-go/mod/net/service/mod/encoding"
+	_ "github.com/sail-services/sail-go/mod/net/service/mod/encoding/brotli"
 	"github.com/sail-services/sail-go/mod/net/service/mod/i18n"
 	"github.com/sail-services/sail-go/mod/net/service/mod/log"
 	"github.com/sail-services/sail-go/mod/net/service/mod/pongo2"
 	"github.com/sail-services/sail-go/mod/net/service/mod/recovery"
+	"github.com/sail-services/sail-go/mod/net/service/mod/secure"
 	"github.com/sail-services/sail-go/mod/net/service/mod/session"
+	_ "github.com/sail-services/sail-go/mod/net/service/mod/session/cookie"
+	_ "github.com/sail-services/sail-go/mod/net/service/mod/session/file"
 	_ "github.com/sail-services/sail-go/mod/net/service/mod/session/memory"
 	"github.com/sail-services/sail-go/mod/net/service/mod/static"
 
@@ -32,6 +37,7 @@ type (
 		Opt     *Opt
 		Base    *Base
 		Pro     *Pro
+		FS      fs.FS // 静态文件、模版与语言文件, 如 embed.FS, 设置后忽略 PathRoot [nil]
 	}
 	Project struct {
 		Name    string
@@ -59,6 +65,11 @@ type (
 		CSRF        string
 		ConnDb      string
 		ConnSession string
+		SessionType string // session 类型 memory / cookie / file 等 [memory]
+		Secure      secure.Options
+		AccessLog   log.Options
+		Recovery    recovery.Options
+		Encoding    encoding.Options // 已注册 br, gzip 与 deflate
 	}
 )
 
@@ -76,8 +87,7 @@ func (web *Web) Init() {
 		web.Project.Version == "" ||
 		web.Project.Url == "" ||
 		web.Base.Port == 0 ||
-		web.Base.PathRootDev == "" ||
-		web.Base.PathRootRelease == "" ||
+		(web.FS == nil && (web.Base.PathRootDev == "" || web.Base.PathRootRelease == "")) ||
 		web.Base.PathTemplate == "" ||
 		web.Base.PathLangs == "" ||
 		web.Base.DefaultLang == "" ||
@@ -112,7 +122,11 @@ func (web *Web) Init() {
 	}
 	if len(web.Pro.StaticFiles) != 0 {
 		for _, file := range web.Pro.StaticFiles {
-			web.Ser.Rou.File(file[0], root_+file[1])
+			if web.FS != nil {
+				web.Ser.Rou.FileFS(file[0], web.FS, fsPath(file[1]))
+			} else {
+				web.Ser.Rou.File(file[0], root_+file[1])
+			}
 		}
 	}
 	if len(web.Pro.PathStatics) != 0 {
@@ -121,28 +135,36 @@ func (web *Web) Init() {
 			opts[i] = static.Options{
 				Prefix: s[0],
 				Dir:    root_ + s[1],
+				Files:  web.fsSub(s[1]),
 			}
 		}
 		web.Ser.Module(static.News(opts))
 	}
 	if service.ModeIsDev() {
-		web.Ser.Module(log.New())
+		web.Ser.Module(log.New(web.Pro.AccessLog))
 	} else {
-		web.Ser.Module(gzip.New(gzip.LEVEL_DEFAULT))
+		web.Ser.Module(encoding.New(web.Pro.Encoding))
 	}
-	web.Ser.Module(recovery.New())
+	web.Ser.Module(recovery.New(web.Pro.Recovery))
+	web.Ser.Module(secure.New(web.Pro.Secure))
 	web.Ser.Module(pongo2.New(pongo2.Options{
 		Dir: root_ + web.Base.PathTemplate,
+		FS:  web.fsSub(web.Base.PathTemplate),
 	}))
 	web.Ser.Module(i18n.New(i18n.Options{
 		Dir:         root_ + web.Base.PathLangs,
+		FS:          web.fsSub(web.Base.PathLangs),
 		Langs:       web.Base.I18nLangs,
 		Names:       web.Base.I18nNames,
 		DefaultLang: web.Base.DefaultLang,
 	}))
-	if len(web.Pro.ConnSession) != 0 || len(web.Pro.CSRF) != 0 {
+	if len(web.Pro.ConnSession) != 0 || len(web.Pro.CSRF) != 0 || len(web.Pro.SessionType) != 0 {
+		session_type := web.Pro.SessionType
+		if session_type == "" {
+			session_type = _SESSION_TYPE
+		}
 		web.Ser.Module(session.New(session.Options{
-			Adapter:    _SESSION_TYPE,
+			Adapter:    session_type,
 			Conn:       web.Pro.ConnSession,
 			Gclifetime: 60 * 60,
 		}))
@@ -155,6 +177,18 @@ func (web *Web) Init() {
 	}
 }
 
+// web.FS 中的子目录, 未设置 FS 时返回 nil 以使用磁盘路径
+func (web *Web) fsSub(dir string) fs.FS {
+	if web.FS == nil {
+		return nil
+	}
+	sub, err := fs.Sub(web.FS, fsPath(dir))
+	if err != nil {
+		panic("web: " + err.Error())
+	}
+	return sub
+}
+
 func (web *Web) Run() {
 	if service.ModeIsDev() {
 		web.Ser.Run(web.Base.Port)
@@ -194,6 +228,14 @@ func (web *Web) EnVar(v string) (ev string) {
 	return base64.StdEncoding.EncodeToString(crypt)
 }
 
+func fsPath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
 func (web *Web) DeVar(ev string) (v string) {
 	if web.Base.SecretKey == "" {
 		web.Ser.Log.Fatalln("Not Set Secret Key")